@@ -15,19 +15,23 @@ type RandomNumberGenerator interface {
 	Intn(n int) int
 }
 
+const MaxConsecutiveGachasLimit = 1000
+
 var rng RandomNumberGenerator
 
 type Item struct {
-	ID    uint  `json:"id"`
-	Ratio int   `json:"ratio"`
-	Tier  *Tier `json:"-"`
+	ID        uint     `json:"id"`
+	Ratio     int      `json:"ratio"`
+	ShopPrice *float64 `json:"shopPrice,omitempty"`
+	Tier      *Tier    `json:"-"`
 }
 
 type Tier struct {
-	ID        uint   `json:"id"`
-	Ratio     int    `json:"ratio"`
-	Items     []Item `json:"items"`
-	ItemCount int64  `json:"-"`
+	ID              uint    `json:"id"`
+	Ratio           int     `json:"ratio"`
+	ConversionValue float64 `json:"conversionValue"`
+	Items           []Item  `json:"items"`
+	ItemCount       int64   `json:"-"`
 }
 
 type Ratioer interface {
@@ -56,12 +60,15 @@ type Policies struct {
 }
 
 type Plan struct {
-	Budget               float64      `json:"budget"`
-	MaxConsecutiveGachas int          `json:"maxConsecutiveGachas"`
-	ItemGoals            bool         `json:"itemGoals"`
-	WantedItems          map[uint]int `json:"wantedItems"`
-	TierGoals            bool         `json:"tierGoals"`
-	WantedTiers          map[uint]int `json:"wantedTiers"`
+	Budget               float64              `json:"budget"`
+	MaxConsecutiveGachas int                  `json:"maxConsecutiveGachas"`
+	ItemGoals            bool                 `json:"itemGoals"`
+	WantedItems          map[uint]int         `json:"wantedItems"`
+	TierGoals            bool                 `json:"tierGoals"`
+	WantedTiers          map[uint]int         `json:"wantedTiers"`
+	Goal                 *Goal                `json:"goal,omitempty"`
+	StopStrategies       []StopStrategyConfig `json:"stopStrategies,omitempty"`
+	UseShop              bool                 `json:"useShop"`
 }
 
 type Request struct {
@@ -75,84 +82,82 @@ type Request struct {
 	GetItemFromID       func(itemID uint) (*Item, error)            `json:"-"`
 	GetItemCountFromIDs func(itemIDs []uint) (int64, error)         `json:"-"`
 	GetTierCountFromIDs func(tierIDs []uint) (int64, error)         `json:"-"`
+	RNG                 RandomNumberGenerator                       `json:"-"`
+	OnPull              func(pull Pull) error                       `json:"-"`
+}
+
+type Pull struct {
+	Index           int     `json:"index"`
+	ItemID          uint    `json:"itemId"`
+	TierID          uint    `json:"tierId"`
+	Pity            bool    `json:"pity"`
+	Discounted      bool    `json:"discounted"`
+	Duplicate       bool    `json:"duplicate"`
+	CumulativeSpend float64 `json:"cumulativeSpend"`
 }
 
 type Result struct {
-	Items         []Item  `json:"items"`
-	GoalsAchieved bool    `json:"goalsAchieved"`
-	MoneySpent    float64 `json:"moneySpent"`
+	Items                []Item         `json:"items"`
+	Pulls                []Pull         `json:"pulls"`
+	GoalsAchieved        bool           `json:"goalsAchieved"`
+	GoalsAchievedViaShop bool           `json:"goalsAchievedViaShop"`
+	MoneySpent           float64        `json:"moneySpent"`
+	Duplicates           int            `json:"duplicates"`
+	ConversionCurrency   float64        `json:"conversionCurrency"`
+	ShopPurchases        []ShopPurchase `json:"shopPurchases"`
+	StopReason           string         `json:"stopReason"`
 }
 
 func Execute(request Request) (Result, error) {
-	result := Result{
-		Items:         make([]Item, 0),
-		GoalsAchieved: false,
-		MoneySpent:    0,
-	}
-	if err := prepareRequest(&request); err != nil {
-		return result, err
-	}
-	getItemFromIndexCached := getItemFromIndexCachedClosure(request.GetItemFromIndex)
-	var count int
-	for i := 0; i < request.Plan.MaxConsecutiveGachas; i++ {
-		if exceedsBudget(i+1, request.Pricing, request.Plan.Budget) {
-			break
-		}
-		var selectedItem Item
-		if shouldSelectPityItem(i+1, request.Policies, result) {
-			selectedItem = *request.Policies.PityItem
-		} else {
-			if item, err := selectRandomItemFromRandomTier(
-				request.Tiers,
-				request.ItemsIncluded,
-				getItemFromIndexCached,
-			); err != nil {
-				return result, err
-			} else {
-				selectedItem = *item
-			}
-		}
-		result.Items = append(result.Items, selectedItem)
-		count = i + 1
-		if (request.Plan.ItemGoals || request.Plan.TierGoals) && meetsGoals(result, request.Plan) {
-			result.GoalsAchieved = true
-			break
+	session, err := NewSession(request)
+	if err != nil {
+		return Result{
+			Items:         make([]Item, 0),
+			Pulls:         make([]Pull, 0),
+			GoalsAchieved: false,
+			MoneySpent:    0,
+			ShopPurchases: make([]ShopPurchase, 0),
+		}, err
+	}
+	for !session.Finished() {
+		if _, err := session.Step(); err != nil {
+			return session.Result(), err
 		}
 	}
-	result.MoneySpent = calculatePrice(count, request.Pricing)
-	return result, nil
+	return session.Result(), nil
 }
 
 func selectRandomItemFromRandomTier(
 	tiers []Tier,
 	itemsIncluded bool,
 	getItemFromIndex func(tierID uint, index int) (*Item, error),
+	rng RandomNumberGenerator,
 ) (*Item, error) {
 	ratioers := make([]Ratioer, len(tiers))
 	for i := range tiers {
 		ratioers[i] = tiers[i]
 	}
-	selectedRatioer := selectRandomRatioer(ratioers)
+	selectedRatioer := selectRandomRatioer(ratioers, rng)
 	selectedTier := selectedRatioer.(Tier)
 	if itemsIncluded {
-		return selectRandomItem(selectedTier.Items), nil
+		return selectRandomItem(selectedTier.Items, rng), nil
 	} else {
 		r := rng.Intn(int(selectedTier.ItemCount))
 		return getItemFromIndex(selectedTier.ID, r)
 	}
 }
 
-func selectRandomItem(items []Item) *Item {
+func selectRandomItem(items []Item, rng RandomNumberGenerator) *Item {
 	ratioers := make([]Ratioer, len(items))
 	for i := range items {
 		ratioers[i] = items[i]
 	}
-	selectedRatioer := selectRandomRatioer(ratioers)
+	selectedRatioer := selectRandomRatioer(ratioers, rng)
 	item := selectedRatioer.(Item)
 	return &item
 }
 
-func selectRandomRatioer(ratioers []Ratioer) Ratioer {
+func selectRandomRatioer(ratioers []Ratioer, rng RandomNumberGenerator) Ratioer {
 	ratioRatioerMap := make(map[int]Ratioer)
 	ratioSum := 0
 	for _, ratioer := range ratioers {
@@ -173,6 +178,22 @@ func selectRandomRatioer(ratioers []Ratioer) Ratioer {
 	return selectedRatioer
 }
 
+func makePull(count int, item Item, pity bool, pricing Pricing) Pull {
+	var tierID uint
+	if item.Tier != nil {
+		tierID = item.Tier.ID
+	}
+	cumulativeSpend := calculatePrice(count, pricing)
+	return Pull{
+		Index:           count,
+		ItemID:          item.ID,
+		TierID:          tierID,
+		Pity:            pity,
+		Discounted:      cumulativeSpend-calculatePrice(count-1, pricing) < pricing.PricePerGacha,
+		CumulativeSpend: cumulativeSpend,
+	}
+}
+
 func exceedsBudget(count int, pricing Pricing, budget float64) bool {
 	price := calculatePrice(count, pricing)
 	return price > budget
@@ -202,57 +223,11 @@ func shouldSelectPityItem(count int, policies Policies, result Result) bool {
 	}
 }
 
-func meetsGoals(result Result, plan Plan) bool {
-	return meetsItemGoals(result, plan) && meetsTierGoals(result, plan)
-}
-
-func meetsItemGoals(result Result, plan Plan) bool {
-	if plan.ItemGoals {
-		for itemID, wantedCount := range plan.WantedItems {
-			count := 0
-			for _, item := range result.Items {
-				if item.ID == itemID {
-					count++
-				}
-				if count >= wantedCount {
-					break
-				}
-			}
-			if count < wantedCount {
-				return false
-			}
-		}
-		return true
-	} else {
-		return true
-	}
-}
-
-func meetsTierGoals(result Result, plan Plan) bool {
-	if plan.TierGoals {
-		for tierID, wantedCount := range plan.WantedTiers {
-			count := 0
-			for _, item := range result.Items {
-				if item.Tier.ID == tierID {
-					count++
-				}
-				if count >= wantedCount {
-					break
-				}
-			}
-			if count < wantedCount {
-				return false
-			}
-		}
-		return true
-	} else {
-		return true
-	}
-}
-
 func prepareRequest(request *Request) error {
 	if request.ItemsIncluded {
-		ensureItemTierReferences(request.Tiers, &request.Policies)
+		if err := ensureItemTierReferences(request.Tiers, &request.Policies); err != nil {
+			return err
+		}
 	} else {
 		if err := countItems(request); err != nil {
 			return err
@@ -271,7 +246,7 @@ func prepareRequest(request *Request) error {
 	return nil
 }
 
-func ensureItemTierReferences(tiers []Tier, policies *Policies) {
+func ensureItemTierReferences(tiers []Tier, policies *Policies) error {
 	for i := 0; i < len(tiers); i++ {
 		for j := 0; j < len(tiers[i].Items); j++ {
 			if tiers[i].Items[j].Tier == nil {
@@ -295,7 +270,11 @@ func ensureItemTierReferences(tiers []Tier, policies *Policies) {
 				break
 			}
 		}
+		if !found {
+			return errors.New("pity item not found in tiers")
+		}
 	}
+	return nil
 }
 
 func countItems(request *Request) error {
@@ -393,6 +372,9 @@ func validateTiersAndItems(request Request) error {
 		if tier.Ratio < 0 {
 			return errors.New("negative tier ratio")
 		}
+		if tier.ConversionValue < 0 {
+			return errors.New("negative tier conversion value")
+		}
 		tierRatioSum += tier.Ratio
 		if request.ItemsIncluded {
 			if len(tier.Items) == 0 {
@@ -403,6 +385,9 @@ func validateTiersAndItems(request Request) error {
 				if item.Ratio < 0 {
 					return errors.New("negative item ratio")
 				}
+				if item.ShopPrice != nil && *item.ShopPrice <= 0 {
+					return errors.New("non-positive item shop price")
+				}
 				itemRatioSum += item.Ratio
 			}
 		}
@@ -463,10 +448,24 @@ func validatePolicies(request Request) error {
 		if _, err := request.GetItemFromID(request.Policies.PityItem.ID); err != nil {
 			return errors.New("pity item not found")
 		}
+		if request.ItemsIncluded && !containsItem(request.Tiers, request.Policies.PityItem.ID) {
+			return errors.New("pity item not found in tiers")
+		}
 	}
 	return nil
 }
 
+func containsItem(tiers []Tier, itemID uint) bool {
+	for _, tier := range tiers {
+		for _, item := range tier.Items {
+			if item.ID == itemID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func validatePlan(request Request) error {
 	if request.Plan.Budget < 0 {
 		return errors.New("negative budget")
@@ -474,7 +473,7 @@ func validatePlan(request Request) error {
 	if request.Plan.MaxConsecutiveGachas < 0 {
 		return errors.New("negative max consecutive gachas")
 	}
-	if request.Plan.MaxConsecutiveGachas > 1000 {
+	if request.Plan.MaxConsecutiveGachas > MaxConsecutiveGachasLimit {
 		return errors.New("exceeded max consecutive gacha limit")
 	}
 	if request.Plan.ItemGoals {
@@ -515,9 +514,47 @@ func validatePlan(request Request) error {
 			return errors.New("some tier not found")
 		}
 	}
+	if _, err := makeStopStrategies(request); err != nil {
+		return err
+	}
+	if request.Plan.Goal != nil {
+		if err := request.Plan.Goal.Validate(); err != nil {
+			return err
+		}
+		if itemIDs := uniqueIDs(request.Plan.Goal.getItemIDs()); len(itemIDs) > 0 {
+			itemCount, err := request.GetItemCountFromIDs(itemIDs)
+			if err != nil {
+				return err
+			}
+			if len(itemIDs) != int(itemCount) {
+				return errors.New("some goal item not found")
+			}
+		}
+		if tierIDs := uniqueIDs(request.Plan.Goal.getTierIDs()); len(tierIDs) > 0 {
+			tierCount, err := request.GetTierCountFromIDs(tierIDs)
+			if err != nil {
+				return err
+			}
+			if len(tierIDs) != int(tierCount) {
+				return errors.New("some goal tier not found")
+			}
+		}
+	}
 	return nil
 }
 
+func uniqueIDs(ids []uint) []uint {
+	uniqueIDs := make([]uint, 0)
+	seen := make(map[uint]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+	return uniqueIDs
+}
+
 func init() {
 	rng = rand.New(rand.NewSource(time.Now().UnixNano()))
 }
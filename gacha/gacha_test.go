@@ -1,6 +1,7 @@
 package gacha
 
 import (
+	"context"
 	"os"
 	"testing"
 )
@@ -78,4 +79,428 @@ func TestExecute(t *testing.T) {
 	if res.Items[0].ID != 1 || res.Items[1].ID != 2 || res.Items[2].ID != 3 || res.Items[3].ID != 3 {
 		t.Error("Unexpected Items")
 	}
+	if len(res.Pulls) != 4 || !res.Pulls[2].Pity || res.Pulls[3].Pity {
+		t.Error("Unexpected Pulls pity")
+	}
+	if res.Pulls[1].Discounted || !res.Pulls[2].Discounted || res.Pulls[2].CumulativeSpend != 90*3 {
+		t.Error("Unexpected Pulls pricing")
+	}
+}
+
+func TestLuckPercentile(t *testing.T) {
+	os.Setenv("TIER_CACHE_SIZE", "10")
+	os.Setenv("ITEM_CACHE_SIZE", "1000")
+	os.Setenv("LUCK_CACHE_SIZE", "10")
+	os.Setenv("LUCK_SIMULATION_RUNS", "1000")
+	commonTier := Tier{
+		ID:    1,
+		Ratio: 9,
+		Items: []Item{{ID: 1, Ratio: 1}},
+	}
+	rareTier := Tier{
+		ID:    2,
+		Ratio: 1,
+		Items: []Item{{ID: 2, Ratio: 1}},
+	}
+	request := Request{
+		Tiers:         []Tier{commonTier, rareTier},
+		ItemsIncluded: true,
+		Pricing: Pricing{
+			PricePerGacha: 100,
+		},
+		Plan: Plan{
+			Budget:               100,
+			MaxConsecutiveGachas: 1,
+		},
+	}
+	lucky, err := LuckPercentile(request, Result{
+		Items:      []Item{{ID: 2, Ratio: 1, Tier: &rareTier}},
+		MoneySpent: 100,
+	})
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if lucky < 90 {
+		t.Error("Unexpected percentile for top tier result")
+	}
+	unlucky, err := LuckPercentile(request, Result{
+		Items:      []Item{{ID: 1, Ratio: 1, Tier: &commonTier}},
+		MoneySpent: 100,
+	})
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if unlucky > 50 {
+		t.Error("Unexpected percentile for common tier result")
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	os.Setenv("TIER_CACHE_SIZE", "10")
+	os.Setenv("ITEM_CACHE_SIZE", "1000")
+	newRequest := func(seededRNG *SeededRNG) Request {
+		return Request{
+			Tiers: []Tier{
+				{ID: 1, Ratio: 9, Items: []Item{{ID: 1, Ratio: 2}, {ID: 2, Ratio: 1}}},
+				{ID: 2, Ratio: 1, Items: []Item{{ID: 3, Ratio: 1}}},
+			},
+			ItemsIncluded: true,
+			Pricing: Pricing{
+				PricePerGacha: 100,
+			},
+			Plan: Plan{
+				Budget:               1000,
+				MaxConsecutiveGachas: 10,
+			},
+			RNG: seededRNG,
+		}
+	}
+	expected, err := Execute(newRequest(NewSeededRNG(42)))
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+
+	seededRNG := NewSeededRNG(42)
+	session, err := NewSession(newRequest(seededRNG))
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if _, err := session.Pull(4); err != nil {
+		t.Error("Unexpected error")
+	}
+	resumedRNG := NewSeededRNG(seededRNG.State)
+	resumed, err := ResumeSession(newRequest(resumedRNG), session.State())
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if _, err := resumed.Pull(100); err != nil {
+		t.Error("Unexpected error")
+	}
+	if !resumed.Finished() {
+		t.Error("Unexpected Finished value")
+	}
+	actual := resumed.Result()
+	if actual.MoneySpent != expected.MoneySpent || len(actual.Pulls) != len(expected.Pulls) {
+		t.Error("Unexpected resumed result")
+	}
+	for i := range expected.Pulls {
+		if actual.Pulls[i] != expected.Pulls[i] {
+			t.Error("Unexpected resumed pulls")
+			break
+		}
+	}
+}
+
+func TestOptimizeBudget(t *testing.T) {
+	os.Setenv("TIER_CACHE_SIZE", "10")
+	os.Setenv("ITEM_CACHE_SIZE", "1000")
+	request := Request{
+		Tiers: []Tier{
+			{ID: 1, Ratio: 1, Items: []Item{{ID: 1, Ratio: 1}}},
+			{ID: 2, Ratio: 1, Items: []Item{{ID: 2, Ratio: 1}}},
+		},
+		ItemsIncluded: true,
+		Pricing: Pricing{
+			PricePerGacha: 10,
+		},
+		Plan: Plan{
+			MaxConsecutiveGachas: 100,
+			TierGoals:            true,
+			WantedTiers:          map[uint]int{2: 1},
+		},
+		RNG: NewSeededRNG(42),
+	}
+	optimization, err := OptimizeBudget(context.Background(), request, 0.875, 4000)
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if !optimization.Achievable {
+		t.Error("Unexpected Achievable value")
+	}
+	if optimization.Budget < 20 || optimization.Budget > 40 {
+		t.Errorf("Unexpected budget %v", optimization.Budget)
+	}
+	if optimization.SuccessRate < 0.875 {
+		t.Error("Unexpected success rate below target")
+	}
+	if optimization.BudgetLow > optimization.Budget || optimization.BudgetHigh < optimization.Budget {
+		t.Error("Unexpected budget interval")
+	}
+	if optimization.SuccessRateLow > optimization.SuccessRate || optimization.SuccessRateHigh < optimization.SuccessRate {
+		t.Error("Unexpected success rate interval")
+	}
+
+	request.Plan.MaxConsecutiveGachas = 1
+	optimization, err = OptimizeBudget(context.Background(), request, 0.9, 1000)
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if optimization.Achievable {
+		t.Error("Unexpected Achievable value")
+	}
+}
+
+func TestOptimizeBudgetWithDiscount(t *testing.T) {
+	os.Setenv("TIER_CACHE_SIZE", "10")
+	os.Setenv("ITEM_CACHE_SIZE", "1000")
+	request := Request{
+		Tiers: []Tier{
+			{ID: 1, Ratio: 1, Items: []Item{{ID: 1, Ratio: 1}}},
+			{ID: 2, Ratio: 1, Items: []Item{{ID: 2, Ratio: 1}}},
+		},
+		ItemsIncluded: true,
+		Pricing: Pricing{
+			PricePerGacha:           100,
+			Discount:                true,
+			DiscountTrigger:         10,
+			DiscountedPricePerGacha: 80,
+		},
+		Plan: Plan{
+			MaxConsecutiveGachas: 100,
+			TierGoals:            true,
+			WantedTiers:          map[uint]int{2: 5},
+		},
+		RNG: NewSeededRNG(42),
+	}
+	optimization, err := OptimizeBudget(context.Background(), request, 0.5, 4000)
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if !optimization.Achievable {
+		t.Error("Unexpected Achievable value")
+	}
+	if optimization.Budget != 900 {
+		t.Errorf("Unexpected budget %v", optimization.Budget)
+	}
+
+	request.Plan.Budget = optimization.Budget
+	request.RNG = NewSeededRNG(7)
+	achieved := 0
+	for i := 0; i < 4000; i++ {
+		result, err := Execute(request)
+		if err != nil {
+			t.Error("Unexpected error")
+		}
+		if result.GoalsAchieved {
+			achieved++
+		}
+	}
+	if float64(achieved)/4000 < 0.5 {
+		t.Error("Unexpected success rate below target")
+	}
+}
+
+func TestExecuteWithShop(t *testing.T) {
+	os.Setenv("TIER_CACHE_SIZE", "10")
+	os.Setenv("ITEM_CACHE_SIZE", "1000")
+	shopPrice := 20.0
+	request := Request{
+		Tiers: []Tier{
+			{ID: 1, Ratio: 1, ConversionValue: 10, Items: []Item{{ID: 1, Ratio: 1}}},
+			{ID: 2, Ratio: 1, Items: []Item{{ID: 2, Ratio: 1, ShopPrice: &shopPrice}}},
+		},
+		ItemsIncluded: true,
+		Pricing: Pricing{
+			PricePerGacha: 100,
+		},
+		Plan: Plan{
+			Budget:               1000,
+			MaxConsecutiveGachas: 10,
+			ItemGoals:            true,
+			WantedItems:          map[uint]int{2: 1},
+			UseShop:              true,
+		},
+		RNG: &RandomNumberGeneratorMock{returnValues: []int{0, 0, 0, 0, 0, 0}},
+	}
+	result, err := Execute(request)
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if !result.GoalsAchieved || !result.GoalsAchievedViaShop {
+		t.Error("Unexpected GoalsAchieved value")
+	}
+	if len(result.Pulls) != 3 || result.MoneySpent != 300 {
+		t.Error("Unexpected pulls")
+	}
+	if result.Pulls[0].Duplicate || !result.Pulls[1].Duplicate || !result.Pulls[2].Duplicate {
+		t.Error("Unexpected Duplicate value")
+	}
+	if result.Duplicates != 2 || result.ConversionCurrency != 20 {
+		t.Error("Unexpected conversion")
+	}
+	if len(result.ShopPurchases) != 1 || result.ShopPurchases[0] != (ShopPurchase{ItemID: 2, Number: 1, Price: 20}) {
+		t.Error("Unexpected shop purchases")
+	}
+
+	request.Plan.WantedItems = map[uint]int{1: 2, 2: 1}
+	request.RNG = &RandomNumberGeneratorMock{returnValues: []int{0, 0, 0, 0, 0, 0, 0, 0, 0}}
+	result, err = Execute(request)
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if len(result.Pulls) != 4 || !result.GoalsAchievedViaShop {
+		t.Error("Unexpected pulls")
+	}
+	if result.Duplicates != 3 || result.ConversionCurrency != 20 {
+		t.Error("Unexpected conversion of a copy still wanted")
+	}
+}
+
+func TestGoalTree(t *testing.T) {
+	c := newCollection()
+	c.add(1, 1)
+	c.add(1, 1)
+	c.add(2, 1)
+	c.add(3, 2)
+	tests := []struct {
+		goal     Goal
+		expected bool
+	}{
+		{Goal{Type: GoalTypeItem, ItemID: 1, Number: 2}, true},
+		{Goal{Type: GoalTypeItem, ItemID: 1, Number: 3}, false},
+		{Goal{Type: GoalTypeTier, TierID: 1, Number: 3}, true},
+		{Goal{Type: GoalTypeDistinct, TierID: 1, Number: 2}, true},
+		{Goal{Type: GoalTypeDistinct, TierID: 1, Number: 3}, false},
+		{Goal{Type: GoalTypeSet, ItemIDs: []uint{1, 2, 3}}, true},
+		{Goal{Type: GoalTypeSet, ItemIDs: []uint{1, 4}}, false},
+		{Goal{Type: GoalTypeGroup, ItemIDs: []uint{2, 3, 4}, Number: 2}, true},
+		{Goal{Type: GoalTypeGroup, ItemIDs: []uint{3, 4}, Number: 2}, false},
+		{Goal{Type: GoalTypeAny, Children: []Goal{
+			{Type: GoalTypeItem, ItemID: 4, Number: 1},
+			{Type: GoalTypeItem, ItemID: 3, Number: 1},
+		}}, true},
+		{Goal{Type: GoalTypeAll, Children: []Goal{
+			{Type: GoalTypeItem, ItemID: 4, Number: 1},
+			{Type: GoalTypeItem, ItemID: 3, Number: 1},
+		}}, false},
+	}
+	for i, test := range tests {
+		if actual := test.goal.isMet(c); actual != test.expected {
+			t.Errorf("Unexpected isMet value for goal %d", i)
+		}
+	}
+
+	os.Setenv("TIER_CACHE_SIZE", "10")
+	os.Setenv("ITEM_CACHE_SIZE", "1000")
+	result, err := Execute(Request{
+		Tiers: []Tier{
+			{ID: 1, Ratio: 1, Items: []Item{{ID: 1, Ratio: 1}, {ID: 2, Ratio: 1}}},
+		},
+		ItemsIncluded: true,
+		Pricing: Pricing{
+			PricePerGacha: 100,
+		},
+		Plan: Plan{
+			Budget:               1000,
+			MaxConsecutiveGachas: 10,
+			Goal: &Goal{Type: GoalTypeAny, Children: []Goal{
+				{Type: GoalTypeItem, ItemID: 1, Number: 2},
+				{Type: GoalTypeDistinct, TierID: 1, Number: 2},
+			}},
+		},
+		RNG: &RandomNumberGeneratorMock{returnValues: []int{0, 0, 0, 1}},
+	})
+	if err != nil {
+		t.Error("Unexpected error")
+	}
+	if !result.GoalsAchieved || len(result.Pulls) != 2 {
+		t.Error("Unexpected goal tree result")
+	}
+
+	catalog := shopCatalog{
+		prices:  map[uint]float64{4: 50},
+		tierIDs: map[uint]uint{4: 2},
+	}
+	purchases, ok := Goal{Type: GoalTypeAll, Children: []Goal{
+		{Type: GoalTypeItem, ItemID: 4, Number: 1},
+		{Type: GoalTypeTier, TierID: 2, Number: 2},
+		{Type: GoalTypeDistinct, TierID: 2, Number: 2},
+	}}.getPurchases(c, catalog)
+	if !ok || len(purchases) != 1 || purchases[4] != 1 {
+		t.Errorf("Unexpected purchases %v", purchases)
+	}
+	if c.itemCounts[4] != 0 || c.tierCounts[2] != 1 {
+		t.Error("Unexpected collection change")
+	}
+
+	if err := (Goal{Type: GoalTypeAll}).Validate(); err == nil {
+		t.Error("Expected error for empty children")
+	}
+}
+
+func TestStopStrategies(t *testing.T) {
+	os.Setenv("TIER_CACHE_SIZE", "10")
+	os.Setenv("ITEM_CACHE_SIZE", "1000")
+	newRequest := func(stopStrategies []StopStrategyConfig, returnValues []int) Request {
+		return Request{
+			Tiers: []Tier{
+				{ID: 1, Ratio: 9, Items: []Item{{ID: 1, Ratio: 1}}},
+				{ID: 2, Ratio: 1, Items: []Item{{ID: 2, Ratio: 1}}},
+			},
+			ItemsIncluded: true,
+			Pricing: Pricing{
+				PricePerGacha: 100,
+			},
+			Plan: Plan{
+				Budget:               1000,
+				MaxConsecutiveGachas: 5,
+				StopStrategies:       stopStrategies,
+			},
+			RNG: &RandomNumberGeneratorMock{returnValues: returnValues},
+		}
+	}
+	tests := []struct {
+		stopStrategies []StopStrategyConfig
+		returnValues   []int
+		pulls          int
+		stopReason     string
+	}{
+		{nil, []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 5, StopReasonMaxConsecutiveGachas},
+		{[]StopStrategyConfig{{Type: StopStrategyFirstTopTier}}, []int{0, 0, 9, 0}, 2, StopStrategyFirstTopTier},
+		{[]StopStrategyConfig{{Type: StopStrategyStopLoss, Amount: 300}}, []int{0, 0, 0, 0, 0, 0, 0, 0}, 3, StopStrategyStopLoss},
+		{[]StopStrategyConfig{{Type: StopStrategyStopLoss, Amount: 300}}, []int{9, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 5, StopReasonMaxConsecutiveGachas},
+	}
+	for i, test := range tests {
+		result, err := Execute(newRequest(test.stopStrategies, test.returnValues))
+		if err != nil {
+			t.Errorf("Unexpected error for case %d", i)
+		}
+		if len(result.Pulls) != test.pulls || result.StopReason != test.stopReason {
+			t.Errorf("Unexpected stop for case %d: %d pulls, %s", i, len(result.Pulls), result.StopReason)
+		}
+	}
+
+	for i, test := range []struct {
+		returnValues []int
+		pulls        int
+	}{
+		{[]int{0, 0, 9, 0}, 2},
+		{[]int{0, 0, 0, 0, 0, 0}, 4},
+	} {
+		request := newRequest([]StopStrategyConfig{{Type: StopStrategyUntilPity}}, test.returnValues)
+		request.Policies = Policies{Pity: true, PityTrigger: 4, PityItem: &Item{ID: 2}}
+		result, err := Execute(request)
+		if err != nil {
+			t.Errorf("Unexpected error for pity case %d", i)
+		}
+		if len(result.Pulls) != test.pulls || result.StopReason != StopStrategyUntilPity {
+			t.Errorf("Unexpected stop for pity case %d: %d pulls, %s", i, len(result.Pulls), result.StopReason)
+		}
+	}
+
+	if _, err := Execute(newRequest([]StopStrategyConfig{{Type: "unknown"}}, nil)); err == nil {
+		t.Error("Expected error for unknown stop strategy")
+	}
+	if _, err := Execute(newRequest([]StopStrategyConfig{{Type: StopStrategyUntilPity}}, nil)); err == nil {
+		t.Error("Expected error for until-pity without pity")
+	}
+	if err := (StopStrategyConfig{Type: "unknown"}).Validate(); err == nil {
+		t.Error("Expected validation error for unknown stop strategy")
+	}
+	if err := (StopStrategyConfig{Type: StopStrategyStopLoss}).Validate(); err == nil {
+		t.Error("Expected validation error for non-positive stop-loss amount")
+	}
+	if err := (StopStrategyConfig{Type: StopStrategyUntilPity}).Validate(); err != nil {
+		t.Error("Unexpected validation error for until-pity")
+	}
 }
@@ -0,0 +1,332 @@
+package gacha
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	GoalTypeAll      = "all"
+	GoalTypeAny      = "any"
+	GoalTypeItem     = "item"
+	GoalTypeTier     = "tier"
+	GoalTypeDistinct = "distinct"
+	GoalTypeSet      = "set"
+	GoalTypeGroup    = "group"
+)
+
+const (
+	MaxGoalDepth = 8
+	MaxGoalNodes = 100
+)
+
+type Goal struct {
+	Type     string `json:"type"`
+	ItemID   uint   `json:"itemId,omitempty"`
+	TierID   uint   `json:"tierId,omitempty"`
+	ItemIDs  []uint `json:"itemIds,omitempty"`
+	Number   int    `json:"number,omitempty"`
+	Children []Goal `json:"children,omitempty"`
+}
+
+type collection struct {
+	itemCounts    map[uint]int
+	tierCounts    map[uint]int
+	tierDistincts map[uint]int
+}
+
+func newCollection() collection {
+	return collection{
+		itemCounts:    make(map[uint]int),
+		tierCounts:    make(map[uint]int),
+		tierDistincts: make(map[uint]int),
+	}
+}
+
+func (c collection) add(itemID, tierID uint) bool {
+	duplicate := c.itemCounts[itemID] > 0
+	c.itemCounts[itemID]++
+	c.tierCounts[tierID]++
+	if !duplicate {
+		c.tierDistincts[tierID]++
+	}
+	return duplicate
+}
+
+func (c collection) copy() collection {
+	return collection{
+		itemCounts:    copyCounts(c.itemCounts),
+		tierCounts:    copyCounts(c.tierCounts),
+		tierDistincts: copyCounts(c.tierDistincts),
+	}
+}
+
+func (plan Plan) HasGoals() bool {
+	return plan.getGoalTree() != nil
+}
+
+func (plan Plan) getGoalTree() *Goal {
+	children := make([]Goal, 0)
+	if plan.ItemGoals {
+		for itemID, number := range plan.WantedItems {
+			children = append(children, Goal{Type: GoalTypeItem, ItemID: itemID, Number: number})
+		}
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].ItemID < children[j].ItemID
+		})
+	}
+	if plan.TierGoals {
+		tierChildren := make([]Goal, 0)
+		for tierID, number := range plan.WantedTiers {
+			tierChildren = append(tierChildren, Goal{Type: GoalTypeTier, TierID: tierID, Number: number})
+		}
+		sort.Slice(tierChildren, func(i, j int) bool {
+			return tierChildren[i].TierID < tierChildren[j].TierID
+		})
+		children = append(children, tierChildren...)
+	}
+	if plan.Goal != nil {
+		if len(children) == 0 {
+			return plan.Goal
+		}
+		children = append(children, *plan.Goal)
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	return &Goal{Type: GoalTypeAll, Children: children}
+}
+
+func (goal Goal) isMet(c collection) bool {
+	switch goal.Type {
+	case GoalTypeAll:
+		for _, child := range goal.Children {
+			if !child.isMet(c) {
+				return false
+			}
+		}
+		return true
+	case GoalTypeAny:
+		for _, child := range goal.Children {
+			if child.isMet(c) {
+				return true
+			}
+		}
+		return false
+	case GoalTypeItem:
+		return c.itemCounts[goal.ItemID] >= goal.Number
+	case GoalTypeTier:
+		return c.tierCounts[goal.TierID] >= goal.Number
+	case GoalTypeDistinct:
+		return c.tierDistincts[goal.TierID] >= goal.Number
+	case GoalTypeSet:
+		for _, itemID := range goal.ItemIDs {
+			if c.itemCounts[itemID] == 0 {
+				return false
+			}
+		}
+		return true
+	case GoalTypeGroup:
+		count := 0
+		for _, itemID := range goal.ItemIDs {
+			count += c.itemCounts[itemID]
+		}
+		return count >= goal.Number
+	default:
+		return false
+	}
+}
+
+func (goal Goal) getItemIDs() []uint {
+	itemIDs := make([]uint, 0)
+	switch goal.Type {
+	case GoalTypeAll, GoalTypeAny:
+		for _, child := range goal.Children {
+			itemIDs = append(itemIDs, child.getItemIDs()...)
+		}
+	case GoalTypeItem:
+		itemIDs = append(itemIDs, goal.ItemID)
+	case GoalTypeSet, GoalTypeGroup:
+		itemIDs = append(itemIDs, goal.ItemIDs...)
+	}
+	return itemIDs
+}
+
+func (goal Goal) getTierIDs() []uint {
+	tierIDs := make([]uint, 0)
+	switch goal.Type {
+	case GoalTypeAll, GoalTypeAny:
+		for _, child := range goal.Children {
+			tierIDs = append(tierIDs, child.getTierIDs()...)
+		}
+	case GoalTypeTier, GoalTypeDistinct:
+		tierIDs = append(tierIDs, goal.TierID)
+	}
+	return tierIDs
+}
+
+func (goal Goal) getNeeds(itemNeeds map[uint]int, tierIDs map[uint]bool) {
+	switch goal.Type {
+	case GoalTypeAll, GoalTypeAny:
+		for _, child := range goal.Children {
+			child.getNeeds(itemNeeds, tierIDs)
+		}
+	case GoalTypeItem:
+		if goal.Number > itemNeeds[goal.ItemID] {
+			itemNeeds[goal.ItemID] = goal.Number
+		}
+	case GoalTypeTier:
+		tierIDs[goal.TierID] = true
+	case GoalTypeSet:
+		for _, itemID := range goal.ItemIDs {
+			if itemNeeds[itemID] < 1 {
+				itemNeeds[itemID] = 1
+			}
+		}
+	case GoalTypeGroup:
+		for _, itemID := range goal.ItemIDs {
+			if goal.Number > itemNeeds[itemID] {
+				itemNeeds[itemID] = goal.Number
+			}
+		}
+	}
+}
+
+func (goal Goal) getPurchases(c collection, catalog shopCatalog) (map[uint]int, bool) {
+	shopPrices := catalog.prices
+	purchases := make(map[uint]int)
+	switch goal.Type {
+	case GoalTypeAll:
+		planned := c.copy()
+		for _, child := range goal.Children {
+			childPurchases, ok := child.getPurchases(planned, catalog)
+			if !ok {
+				return nil, false
+			}
+			for itemID, number := range childPurchases {
+				purchases[itemID] += number
+				for i := 0; i < number; i++ {
+					planned.add(itemID, catalog.tierIDs[itemID])
+				}
+			}
+		}
+		return purchases, true
+	case GoalTypeAny:
+		var cheapest map[uint]int
+		cheapestCost := math.Inf(1)
+		for _, child := range goal.Children {
+			childPurchases, ok := child.getPurchases(c, catalog)
+			if !ok {
+				continue
+			}
+			if cost := getPurchaseCost(childPurchases, shopPrices); cost < cheapestCost {
+				cheapest = childPurchases
+				cheapestCost = cost
+			}
+		}
+		return cheapest, cheapest != nil
+	case GoalTypeItem:
+		if missing := goal.Number - c.itemCounts[goal.ItemID]; missing > 0 {
+			if _, ok := shopPrices[goal.ItemID]; !ok {
+				return nil, false
+			}
+			purchases[goal.ItemID] = missing
+		}
+		return purchases, true
+	case GoalTypeSet:
+		for _, itemID := range goal.ItemIDs {
+			if c.itemCounts[itemID] > 0 {
+				continue
+			}
+			if _, ok := shopPrices[itemID]; !ok {
+				return nil, false
+			}
+			purchases[itemID] = 1
+		}
+		return purchases, true
+	case GoalTypeGroup:
+		count := 0
+		for _, itemID := range goal.ItemIDs {
+			count += c.itemCounts[itemID]
+		}
+		missing := goal.Number - count
+		if missing <= 0 {
+			return purchases, true
+		}
+		cheapestItemID, found := uint(0), false
+		for _, itemID := range goal.ItemIDs {
+			shopPrice, ok := shopPrices[itemID]
+			if ok && (!found || shopPrice < shopPrices[cheapestItemID]) {
+				cheapestItemID, found = itemID, true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		purchases[cheapestItemID] = missing
+		return purchases, true
+	default:
+		return purchases, goal.isMet(c)
+	}
+}
+
+func getPurchaseCost(purchases map[uint]int, shopPrices map[uint]float64) float64 {
+	cost := 0.0
+	for itemID, number := range purchases {
+		cost += shopPrices[itemID] * float64(number)
+	}
+	return cost
+}
+
+func copyCounts(counts map[uint]int) map[uint]int {
+	copied := make(map[uint]int, len(counts))
+	for id, count := range counts {
+		copied[id] = count
+	}
+	return copied
+}
+
+func (goal Goal) Validate() error {
+	nodes := 0
+	return validateGoal(goal, 1, &nodes)
+}
+
+func validateGoal(goal Goal, depth int, nodes *int) error {
+	*nodes++
+	if depth > MaxGoalDepth {
+		return errors.New("goal tree too deep")
+	}
+	if *nodes > MaxGoalNodes {
+		return errors.New("goal tree too large")
+	}
+	switch goal.Type {
+	case GoalTypeAll, GoalTypeAny:
+		if len(goal.Children) == 0 {
+			return errors.New("goal children empty")
+		}
+		for _, child := range goal.Children {
+			if err := validateGoal(child, depth+1, nodes); err != nil {
+				return err
+			}
+		}
+	case GoalTypeItem, GoalTypeTier, GoalTypeDistinct:
+		if goal.Number <= 0 {
+			return errors.New("non-positive goal number")
+		}
+	case GoalTypeSet:
+		if len(goal.ItemIDs) == 0 {
+			return errors.New("goal item set empty")
+		}
+	case GoalTypeGroup:
+		if len(goal.ItemIDs) == 0 {
+			return errors.New("goal item group empty")
+		}
+		if goal.Number <= 0 {
+			return errors.New("non-positive goal number")
+		}
+	default:
+		return errors.New("unknown goal type: " + goal.Type)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package gacha
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+type outcome struct {
+	goalsAchieved bool
+	pulls         int
+	moneySpent    float64
+	topTierCount  int
+}
+
+type luckBaselineKey struct {
+	Tiers    []Tier   `json:"tiers"`
+	Pricing  Pricing  `json:"pricing"`
+	Policies Policies `json:"policies"`
+	Plan     Plan     `json:"plan"`
+}
+
+const MaxLuckBaselineWorkers = 2
+
+var luckBaselinesInFlight = make(map[[32]byte]bool)
+var luckBaselinesMutex sync.Mutex
+
+var luckBaselineCache *lru.Cache
+var luckSimulationRuns int
+var luckCacheInitialized bool = false
+var luckCacheInitMutex sync.Mutex
+
+func LuckPercentile(request Request, result Result) (float64, error) {
+	key, err := getLuckBaselineKey(request)
+	if err != nil {
+		return 0, err
+	}
+	baseline, ok := getCachedLuckBaseline(key)
+	if !ok {
+		if baseline, err = computeLuckBaseline(key, request); err != nil {
+			return 0, err
+		}
+	}
+	return getPercentile(baseline, request, result), nil
+}
+
+func LuckPercentileIfReady(request Request, result Result) *float64 {
+	key, err := getLuckBaselineKey(request)
+	if err != nil {
+		return nil
+	}
+	baseline, ok := getCachedLuckBaseline(key)
+	if !ok {
+		startLuckBaseline(key, request)
+		return nil
+	}
+	percentile := getPercentile(baseline, request, result)
+	return &percentile
+}
+
+func getPercentile(baseline []outcome, request Request, result Result) float64 {
+	if len(baseline) == 0 {
+		return 0
+	}
+	topTierIDs := getTopTierIDs(request.Tiers)
+	target := mapOutcome(result, topTierIDs)
+	worse := 0
+	ties := 0
+	for _, o := range baseline {
+		switch c := compareOutcome(target, o); {
+		case c > 0:
+			worse++
+		case c == 0:
+			ties++
+		}
+	}
+	return (float64(worse) + 0.5*float64(ties)) / float64(len(baseline)) * 100
+}
+
+func getLuckBaselineKey(request Request) ([32]byte, error) {
+	keyJSON, err := json.Marshal(luckBaselineKey{
+		Tiers:    request.Tiers,
+		Pricing:  request.Pricing,
+		Policies: request.Policies,
+		Plan:     request.Plan,
+	})
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(keyJSON), nil
+}
+
+func getCachedLuckBaseline(key [32]byte) ([]outcome, bool) {
+	luckCacheInitMutex.Lock()
+	if !luckCacheInitialized {
+		initLuckCache()
+	}
+	luckCacheInitMutex.Unlock()
+	if baseline, ok := luckBaselineCache.Get(key); ok {
+		return baseline.([]outcome), true
+	}
+	return nil, false
+}
+
+func startLuckBaseline(key [32]byte, request Request) {
+	luckBaselinesMutex.Lock()
+	defer luckBaselinesMutex.Unlock()
+	if luckBaselinesInFlight[key] || len(luckBaselinesInFlight) >= MaxLuckBaselineWorkers {
+		return
+	}
+	luckBaselinesInFlight[key] = true
+	request.Tiers = append([]Tier(nil), request.Tiers...)
+	go func() {
+		computeLuckBaseline(key, request)
+		luckBaselinesMutex.Lock()
+		delete(luckBaselinesInFlight, key)
+		luckBaselinesMutex.Unlock()
+	}()
+}
+
+func computeLuckBaseline(key [32]byte, request Request) ([]outcome, error) {
+	simulationRequest := request
+	simulationRequest.OnPull = nil
+	simulationRequest.RNG = nil
+	sim, err := newSimulation(simulationRequest)
+	if err != nil {
+		return nil, err
+	}
+	baselineRNG := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(key[:8]))))
+	baseline := make([]outcome, 0, luckSimulationRuns)
+	for i := 0; i < luckSimulationRuns; i++ {
+		result, err := sim.execute(baselineRNG)
+		if err != nil {
+			return nil, err
+		}
+		baseline = append(baseline, mapOutcome(result, sim.topTierIDs))
+	}
+	luckBaselineCache.Add(key, baseline)
+	return baseline, nil
+}
+
+func compareOutcome(a, b outcome) int {
+	if a.goalsAchieved != b.goalsAchieved {
+		if a.goalsAchieved {
+			return 1
+		}
+		return -1
+	}
+	if a.goalsAchieved && a.pulls != b.pulls {
+		return b.pulls - a.pulls
+	}
+	if a.moneySpent != b.moneySpent {
+		if a.moneySpent < b.moneySpent {
+			return 1
+		}
+		return -1
+	}
+	return a.topTierCount - b.topTierCount
+}
+
+func mapOutcome(result Result, topTierIDs map[uint]bool) outcome {
+	topTierCount := 0
+	for _, item := range result.Items {
+		if item.Tier != nil && topTierIDs[item.Tier.ID] {
+			topTierCount++
+		}
+	}
+	return outcome{
+		goalsAchieved: result.GoalsAchieved,
+		pulls:         len(result.Items),
+		moneySpent:    result.MoneySpent,
+		topTierCount:  topTierCount,
+	}
+}
+
+func getTopTierIDs(tiers []Tier) map[uint]bool {
+	minRatio := 0
+	for _, tier := range tiers {
+		if tier.Ratio > 0 && (minRatio == 0 || tier.Ratio < minRatio) {
+			minRatio = tier.Ratio
+		}
+	}
+	topTierIDs := make(map[uint]bool)
+	for _, tier := range tiers {
+		if tier.Ratio > 0 && tier.Ratio == minRatio {
+			topTierIDs[tier.ID] = true
+		}
+	}
+	return topTierIDs
+}
+
+func initLuckCache() {
+	luckCacheSizeStr := os.Getenv("LUCK_CACHE_SIZE")
+	luckCacheSize, err := strconv.Atoi(luckCacheSizeStr)
+	if err != nil {
+		panic(err)
+	}
+	luckBaselineCache, err = lru.New(luckCacheSize)
+	if err != nil {
+		panic(err)
+	}
+	luckSimulationRunsStr := os.Getenv("LUCK_SIMULATION_RUNS")
+	luckSimulationRuns, err = strconv.Atoi(luckSimulationRunsStr)
+	if err != nil {
+		panic(err)
+	}
+	luckCacheInitialized = true
+}
@@ -0,0 +1,169 @@
+package gacha
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const confidenceZ = 1.96
+
+const MaxBudgetVerifications = 3
+
+type BudgetOptimization struct {
+	TargetProbability float64 `json:"targetProbability"`
+	Runs              int     `json:"runs"`
+	Achievable        bool    `json:"achievable"`
+	Budget            float64 `json:"budget"`
+	BudgetLow         float64 `json:"budgetLow"`
+	BudgetHigh        float64 `json:"budgetHigh"`
+	SuccessRate       float64 `json:"successRate"`
+	SuccessRateLow    float64 `json:"successRateLow"`
+	SuccessRateHigh   float64 `json:"successRateHigh"`
+	MeanPulls         float64 `json:"meanPulls"`
+}
+
+func OptimizeBudget(ctx context.Context, request Request, targetProbability float64, runs int) (BudgetOptimization, error) {
+	optimization := BudgetOptimization{
+		TargetProbability: targetProbability,
+		Runs:              runs,
+	}
+	if targetProbability <= 0 || targetProbability > 1 {
+		return optimization, errors.New("target probability out of range")
+	}
+	if runs <= 0 {
+		return optimization, errors.New("non-positive runs")
+	}
+	if !request.Plan.HasGoals() {
+		return optimization, errors.New("no goals to optimize for")
+	}
+
+	// A run under a smaller budget draws the same pulls until it stops
+	// before the first pull whose cumulative price exceeds the budget.
+	// Discounts can lower the cumulative price, so the budget a successful
+	// run needs is the peak price over its pulls rather than its spend.
+	if request.Plan.MaxConsecutiveGachas == 0 {
+		request.Plan.MaxConsecutiveGachas = MaxConsecutiveGachasLimit
+	}
+	peakPrices := getPeakPrices(request.Plan.MaxConsecutiveGachas, request.Pricing)
+	maxBudget := peakPrices[request.Plan.MaxConsecutiveGachas]
+	request.Plan.Budget = maxBudget
+	request.OnPull = nil
+	if request.RNG == nil {
+		request.RNG = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	sim, err := newSimulation(request)
+	if err != nil {
+		return optimization, err
+	}
+	successes, err := simulateBudget(ctx, *sim, request.RNG, runs)
+	if err != nil {
+		return optimization, err
+	}
+	budgets := make([]float64, 0, len(successes))
+	for _, success := range successes {
+		budgets = append(budgets, peakPrices[success.pulls])
+	}
+	sort.Float64s(budgets)
+
+	k := int(math.Ceil(targetProbability * float64(runs)))
+	if k > len(budgets) {
+		optimization.Budget = maxBudget
+		optimization.BudgetLow = maxBudget
+		optimization.BudgetHigh = maxBudget
+		setBudgetOutcomes(&optimization, successes, runs)
+		return optimization, nil
+	}
+
+	spread := confidenceZ * math.Sqrt(float64(runs)*targetProbability*(1-targetProbability))
+	low := int(math.Floor(float64(k) - spread))
+	high := int(math.Ceil(float64(k) + spread))
+	if low < 1 {
+		low = 1
+	}
+	optimization.BudgetLow = budgets[low-1]
+	if high > len(budgets) {
+		optimization.BudgetHigh = maxBudget
+	} else {
+		optimization.BudgetHigh = budgets[high-1]
+	}
+
+	// The quantile is picked from the same runs it is measured on, so the
+	// chosen budget is simulated again and raised to the next candidate
+	// while the fresh runs fall short of the target.
+	for i, verifications := k-1, 0; i < len(budgets) && verifications < MaxBudgetVerifications; verifications++ {
+		budgetSim := *sim
+		budgetSim.request.Plan.Budget = budgets[i]
+		successes, err := simulateBudget(ctx, budgetSim, request.RNG, runs)
+		if err != nil {
+			return optimization, err
+		}
+		optimization.Budget = budgets[i]
+		setBudgetOutcomes(&optimization, successes, runs)
+		optimization.Achievable = optimization.SuccessRate >= targetProbability
+		if optimization.Achievable {
+			break
+		}
+		for i < len(budgets) && budgets[i] <= optimization.Budget {
+			i++
+		}
+	}
+	if optimization.BudgetHigh < optimization.Budget {
+		optimization.BudgetHigh = optimization.Budget
+	}
+	return optimization, nil
+}
+
+func simulateBudget(ctx context.Context, sim simulation, rng RandomNumberGenerator, runs int) ([]outcome, error) {
+	successes := make([]outcome, 0, runs)
+	for i := 0; i < runs; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := sim.execute(rng)
+		if err != nil {
+			return nil, err
+		}
+		if result.GoalsAchieved {
+			successes = append(successes, outcome{
+				goalsAchieved: true,
+				pulls:         len(result.Pulls),
+				moneySpent:    result.MoneySpent,
+			})
+		}
+	}
+	return successes, nil
+}
+
+func getPeakPrices(count int, pricing Pricing) []float64 {
+	peakPrices := make([]float64, count+1)
+	for i := 1; i <= count; i++ {
+		peakPrices[i] = math.Max(peakPrices[i-1], calculatePrice(i, pricing))
+	}
+	return peakPrices
+}
+
+func setBudgetOutcomes(optimization *BudgetOptimization, successes []outcome, runs int) {
+	optimization.SuccessRate = float64(len(successes)) / float64(runs)
+	optimization.SuccessRateLow, optimization.SuccessRateHigh = wilsonInterval(len(successes), runs)
+	if len(successes) == 0 {
+		return
+	}
+	pulls := 0
+	for _, success := range successes {
+		pulls += success.pulls
+	}
+	optimization.MeanPulls = float64(pulls) / float64(len(successes))
+}
+
+func wilsonInterval(successes, runs int) (float64, float64) {
+	n := float64(runs)
+	p := float64(successes) / n
+	z2 := confidenceZ * confidenceZ
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := confidenceZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / (1 + z2/n)
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
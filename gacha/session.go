@@ -0,0 +1,289 @@
+package gacha
+
+type SeededRNG struct {
+	State uint64 `json:"state"`
+}
+
+type SessionState struct {
+	Pulls                []Pull         `json:"pulls"`
+	GoalsAchieved        bool           `json:"goalsAchieved"`
+	GoalsAchievedViaShop bool           `json:"goalsAchievedViaShop"`
+	ShopPurchases        []ShopPurchase `json:"shopPurchases"`
+	StopReason           string         `json:"stopReason"`
+	Finished             bool           `json:"finished"`
+}
+
+type Session struct {
+	request          Request
+	rng              RandomNumberGenerator
+	getItemFromIndex func(tierID uint, index int) (*Item, error)
+	goal             *Goal
+	conversionValues map[uint]float64
+	itemNeeds        map[uint]int
+	neededTierIDs    map[uint]bool
+	shopCatalog      shopCatalog
+	collection       collection
+	stopStrategies   []StopStrategy
+	topTierIDs       map[uint]bool
+	stopContext      StopContext
+	result           Result
+	count            int
+	finished         bool
+}
+
+func NewSeededRNG(seed uint64) *SeededRNG {
+	return &SeededRNG{State: seed}
+}
+
+func (seededRNG *SeededRNG) Intn(n int) int {
+	seededRNG.State += 0x9e3779b97f4a7c15
+	z := seededRNG.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return int(z % uint64(n))
+}
+
+type simulation struct {
+	request          Request
+	getItemFromIndex func(tierID uint, index int) (*Item, error)
+	goal             *Goal
+	conversionValues map[uint]float64
+	itemNeeds        map[uint]int
+	neededTierIDs    map[uint]bool
+	shopCatalog      shopCatalog
+	topTierIDs       map[uint]bool
+}
+
+func NewSession(request Request) (*Session, error) {
+	sim, err := newSimulation(request)
+	if err != nil {
+		return nil, err
+	}
+	return sim.newSession(request.RNG)
+}
+
+func newSimulation(request Request) (*simulation, error) {
+	if err := prepareRequest(&request); err != nil {
+		return nil, err
+	}
+	goal := request.Plan.getGoalTree()
+	catalog, err := getShopCatalog(request, goal)
+	if err != nil {
+		return nil, err
+	}
+	itemNeeds := make(map[uint]int)
+	neededTierIDs := make(map[uint]bool)
+	if goal != nil {
+		goal.getNeeds(itemNeeds, neededTierIDs)
+	}
+	return &simulation{
+		request:          request,
+		getItemFromIndex: getItemFromIndexCachedClosure(request.GetItemFromIndex),
+		goal:             goal,
+		conversionValues: getConversionValues(request.Tiers),
+		itemNeeds:        itemNeeds,
+		neededTierIDs:    neededTierIDs,
+		shopCatalog:      catalog,
+		topTierIDs:       getTopTierIDs(request.Tiers),
+	}, nil
+}
+
+func (sim *simulation) newSession(sessionRNG RandomNumberGenerator) (*Session, error) {
+	stopStrategies, err := makeStopStrategies(sim.request)
+	if err != nil {
+		return nil, err
+	}
+	if sessionRNG == nil {
+		sessionRNG = rng
+	}
+	return &Session{
+		request:          sim.request,
+		rng:              sessionRNG,
+		getItemFromIndex: sim.getItemFromIndex,
+		goal:             sim.goal,
+		conversionValues: sim.conversionValues,
+		itemNeeds:        sim.itemNeeds,
+		neededTierIDs:    sim.neededTierIDs,
+		shopCatalog:      sim.shopCatalog,
+		collection:       newCollection(),
+		stopStrategies:   stopStrategies,
+		topTierIDs:       sim.topTierIDs,
+		result: Result{
+			Items:         make([]Item, 0),
+			Pulls:         make([]Pull, 0),
+			GoalsAchieved: false,
+			MoneySpent:    0,
+			ShopPurchases: make([]ShopPurchase, 0),
+		},
+	}, nil
+}
+
+func (sim *simulation) execute(sessionRNG RandomNumberGenerator) (Result, error) {
+	session, err := sim.newSession(sessionRNG)
+	if err != nil {
+		return Result{}, err
+	}
+	for !session.Finished() {
+		if _, err := session.Step(); err != nil {
+			return session.Result(), err
+		}
+	}
+	return session.Result(), nil
+}
+
+func ResumeSession(request Request, state SessionState) (*Session, error) {
+	session, err := NewSession(request)
+	if err != nil {
+		return nil, err
+	}
+	for _, pull := range state.Pulls {
+		session.result.Items = append(session.result.Items, Item{
+			ID:   pull.ItemID,
+			Tier: &Tier{ID: pull.TierID},
+		})
+		session.record(pull)
+	}
+	session.count = len(state.Pulls)
+	session.result.GoalsAchieved = state.GoalsAchieved
+	session.result.GoalsAchievedViaShop = state.GoalsAchievedViaShop
+	session.result.StopReason = state.StopReason
+	if state.ShopPurchases != nil {
+		session.result.ShopPurchases = state.ShopPurchases
+	}
+	session.finished = state.Finished
+	return session, nil
+}
+
+func (session *Session) Step() (*Pull, error) {
+	if session.finished {
+		return nil, nil
+	}
+	request := session.request
+	i := session.count
+	if i >= request.Plan.MaxConsecutiveGachas {
+		session.stop(StopReasonMaxConsecutiveGachas)
+		return nil, nil
+	}
+	if exceedsBudget(i+1, request.Pricing, request.Plan.Budget) {
+		session.stop(StopReasonBudgetExceeded)
+		return nil, nil
+	}
+	var selectedItem Item
+	pity := shouldSelectPityItem(i+1, request.Policies, session.result)
+	if pity {
+		selectedItem = *request.Policies.PityItem
+	} else {
+		if item, err := selectRandomItemFromRandomTier(
+			request.Tiers,
+			request.ItemsIncluded,
+			session.getItemFromIndex,
+			session.rng,
+		); err != nil {
+			return nil, err
+		} else {
+			selectedItem = *item
+		}
+	}
+	session.result.Items = append(session.result.Items, selectedItem)
+	session.count = i + 1
+	pull := session.record(makePull(session.count, selectedItem, pity, request.Pricing))
+	if request.OnPull != nil {
+		if err := request.OnPull(pull); err != nil {
+			return nil, err
+		}
+	}
+	if session.goal != nil {
+		if session.goal.isMet(session.collection) {
+			session.result.GoalsAchieved = true
+			session.stop(StopReasonGoalsAchieved)
+		} else if request.Plan.UseShop {
+			purchases, ok := planShopPurchases(*session.goal, session.collection, session.shopCatalog, session.result.ConversionCurrency)
+			if ok {
+				session.result.ShopPurchases = purchases
+				session.result.GoalsAchieved = true
+				session.result.GoalsAchievedViaShop = true
+				session.stop(StopReasonGoalsAchieved)
+			}
+		}
+	}
+	for j, stopStrategy := range session.stopStrategies {
+		if session.finished {
+			break
+		}
+		if stopStrategy.ShouldStop(session.stopContext) {
+			session.stop(request.Plan.StopStrategies[j].Type)
+		}
+	}
+	return &pull, nil
+}
+
+func (session *Session) record(pull Pull) Pull {
+	pull.Duplicate = session.collect(pull.ItemID, pull.TierID)
+	session.result.Pulls = append(session.result.Pulls, pull)
+	session.stopContext.Pull = pull
+	session.stopContext.Pulls = pull.Index
+	session.stopContext.MoneySpent = pull.CumulativeSpend
+	if session.topTierIDs[pull.TierID] {
+		session.stopContext.TopTierPulls++
+	}
+	if pull.Pity {
+		session.stopContext.PityTriggered = true
+	}
+	if policies := session.request.Policies; policies.Pity && policies.PityItem != nil && pull.ItemID == policies.PityItem.ID {
+		session.stopContext.PityItemObtained = true
+	}
+	return pull
+}
+
+func (session *Session) stop(stopReason string) {
+	session.result.StopReason = stopReason
+	session.finished = true
+}
+
+func (session *Session) collect(itemID, tierID uint) bool {
+	if !session.collection.add(itemID, tierID) {
+		return false
+	}
+	session.result.Duplicates++
+	// Copies that still count towards a goal are kept rather than converted.
+	if session.collection.itemCounts[itemID] > session.itemNeeds[itemID] && !session.neededTierIDs[tierID] {
+		session.result.ConversionCurrency += session.conversionValues[tierID]
+	}
+	return true
+}
+
+func (session *Session) Pull(n int) ([]Pull, error) {
+	pulls := make([]Pull, 0)
+	for i := 0; i < n && !session.finished; i++ {
+		pull, err := session.Step()
+		if err != nil {
+			return pulls, err
+		}
+		if pull != nil {
+			pulls = append(pulls, *pull)
+		}
+	}
+	return pulls, nil
+}
+
+func (session *Session) Finished() bool {
+	return session.finished
+}
+
+func (session *Session) Result() Result {
+	result := session.result
+	result.MoneySpent = calculatePrice(session.count, session.request.Pricing)
+	return result
+}
+
+func (session *Session) State() SessionState {
+	return SessionState{
+		Pulls:                session.result.Pulls,
+		GoalsAchieved:        session.result.GoalsAchieved,
+		GoalsAchievedViaShop: session.result.GoalsAchievedViaShop,
+		ShopPurchases:        session.result.ShopPurchases,
+		StopReason:           session.result.StopReason,
+		Finished:             session.finished,
+	}
+}
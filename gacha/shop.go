@@ -0,0 +1,80 @@
+package gacha
+
+import "sort"
+
+type ShopPurchase struct {
+	ItemID uint    `json:"itemId"`
+	Number int     `json:"number"`
+	Price  float64 `json:"price"`
+}
+
+func getConversionValues(tiers []Tier) map[uint]float64 {
+	conversionValues := make(map[uint]float64)
+	for _, tier := range tiers {
+		conversionValues[tier.ID] = tier.ConversionValue
+	}
+	return conversionValues
+}
+
+type shopCatalog struct {
+	prices  map[uint]float64
+	tierIDs map[uint]uint
+}
+
+func getShopCatalog(request Request, goal *Goal) (shopCatalog, error) {
+	catalog := shopCatalog{
+		prices:  make(map[uint]float64),
+		tierIDs: make(map[uint]uint),
+	}
+	if !request.Plan.UseShop || goal == nil {
+		return catalog, nil
+	}
+	for _, itemID := range uniqueIDs(goal.getItemIDs()) {
+		var item *Item
+		if request.ItemsIncluded {
+			item = findItem(request.Tiers, itemID)
+		} else {
+			var err error
+			if item, err = request.GetItemFromID(itemID); err != nil {
+				return catalog, err
+			}
+		}
+		if item != nil && item.ShopPrice != nil {
+			catalog.prices[itemID] = *item.ShopPrice
+			if item.Tier != nil {
+				catalog.tierIDs[itemID] = item.Tier.ID
+			}
+		}
+	}
+	return catalog, nil
+}
+
+func findItem(tiers []Tier, itemID uint) *Item {
+	for i := range tiers {
+		for j := range tiers[i].Items {
+			if tiers[i].Items[j].ID == itemID {
+				return &tiers[i].Items[j]
+			}
+		}
+	}
+	return nil
+}
+
+func planShopPurchases(goal Goal, c collection, catalog shopCatalog, currency float64) ([]ShopPurchase, bool) {
+	purchaseNumbers, ok := goal.getPurchases(c, catalog)
+	if !ok || len(purchaseNumbers) == 0 || getPurchaseCost(purchaseNumbers, catalog.prices) > currency {
+		return nil, false
+	}
+	purchases := make([]ShopPurchase, 0)
+	for itemID, number := range purchaseNumbers {
+		purchases = append(purchases, ShopPurchase{
+			ItemID: itemID,
+			Number: number,
+			Price:  catalog.prices[itemID] * float64(number),
+		})
+	}
+	sort.Slice(purchases, func(i, j int) bool {
+		return purchases[i].ItemID < purchases[j].ItemID
+	})
+	return purchases, true
+}
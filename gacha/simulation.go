@@ -0,0 +1,75 @@
+package gacha
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+type SimulationSummary struct {
+	Runs             int     `json:"runs"`
+	GoalsAchieved    int     `json:"goalsAchieved"`
+	SuccessRate      float64 `json:"successRate"`
+	MeanMoneySpent   float64 `json:"meanMoneySpent"`
+	MedianMoneySpent float64 `json:"medianMoneySpent"`
+	P90MoneySpent    float64 `json:"p90MoneySpent"`
+	MeanPulls        float64 `json:"meanPulls"`
+}
+
+func Simulate(ctx context.Context, request Request, runs int, onProgress func(done int)) (SimulationSummary, error) {
+	summary := SimulationSummary{}
+	if request.RNG == nil {
+		request.RNG = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	sim, err := newSimulation(request)
+	if err != nil {
+		return summary, err
+	}
+	moneySpents := make([]float64, 0, runs)
+	pulls := 0
+	for i := 0; i < runs; i++ {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		result, err := sim.execute(request.RNG)
+		if err != nil {
+			return summary, err
+		}
+		if result.GoalsAchieved {
+			summary.GoalsAchieved++
+		}
+		moneySpents = append(moneySpents, result.MoneySpent)
+		pulls += len(result.Items)
+		if onProgress != nil {
+			onProgress(i + 1)
+		}
+	}
+	summary.Runs = runs
+	if runs == 0 {
+		return summary, nil
+	}
+	moneySpentSum := 0.0
+	for _, moneySpent := range moneySpents {
+		moneySpentSum += moneySpent
+	}
+	sort.Float64s(moneySpents)
+	summary.SuccessRate = float64(summary.GoalsAchieved) / float64(runs)
+	summary.MeanMoneySpent = moneySpentSum / float64(runs)
+	summary.MedianMoneySpent = quantile(moneySpents, 0.5)
+	summary.P90MoneySpent = quantile(moneySpents, 0.9)
+	summary.MeanPulls = float64(pulls) / float64(runs)
+	return summary, nil
+}
+
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,114 @@
+package gacha
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	StopReasonGoalsAchieved        = "goalsAchieved"
+	StopReasonBudgetExceeded       = "budgetExceeded"
+	StopReasonMaxConsecutiveGachas = "maxConsecutiveGachas"
+)
+
+const (
+	StopStrategyFirstTopTier = "first-top-tier"
+	StopStrategyStopLoss     = "stop-loss"
+	StopStrategyUntilPity    = "until-pity"
+)
+
+type StopStrategyConfig struct {
+	Type   string  `json:"type"`
+	Amount float64 `json:"amount,omitempty"`
+}
+
+type StopContext struct {
+	Pull             Pull
+	Pulls            int
+	MoneySpent       float64
+	TopTierPulls     int
+	PityTriggered    bool
+	PityItemObtained bool
+}
+
+type StopStrategy interface {
+	ShouldStop(stopContext StopContext) bool
+}
+
+type StopStrategyFactory func(config StopStrategyConfig, request Request) (StopStrategy, error)
+
+type StopStrategyFunc func(stopContext StopContext) bool
+
+func (f StopStrategyFunc) ShouldStop(stopContext StopContext) bool {
+	return f(stopContext)
+}
+
+var stopStrategyFactories = make(map[string]StopStrategyFactory)
+var stopStrategyFactoriesMutex sync.RWMutex
+
+func RegisterStopStrategy(name string, factory StopStrategyFactory) {
+	stopStrategyFactoriesMutex.Lock()
+	defer stopStrategyFactoriesMutex.Unlock()
+	stopStrategyFactories[name] = factory
+}
+
+func (config StopStrategyConfig) Validate() error {
+	stopStrategyFactoriesMutex.RLock()
+	_, ok := stopStrategyFactories[config.Type]
+	stopStrategyFactoriesMutex.RUnlock()
+	if !ok {
+		return errors.New("unknown stop strategy: " + config.Type)
+	}
+	if config.Type == StopStrategyStopLoss && config.Amount <= 0 {
+		return errors.New("non-positive stop-loss amount")
+	}
+	return nil
+}
+
+func makeStopStrategies(request Request) ([]StopStrategy, error) {
+	stopStrategyFactoriesMutex.RLock()
+	defer stopStrategyFactoriesMutex.RUnlock()
+	stopStrategies := make([]StopStrategy, 0)
+	for _, config := range request.Plan.StopStrategies {
+		factory, ok := stopStrategyFactories[config.Type]
+		if !ok {
+			return nil, errors.New("unknown stop strategy: " + config.Type)
+		}
+		stopStrategy, err := factory(config, request)
+		if err != nil {
+			return nil, err
+		}
+		stopStrategies = append(stopStrategies, stopStrategy)
+	}
+	return stopStrategies, nil
+}
+
+func newFirstTopTierStopStrategy(StopStrategyConfig, Request) (StopStrategy, error) {
+	return StopStrategyFunc(func(stopContext StopContext) bool {
+		return stopContext.TopTierPulls > 0
+	}), nil
+}
+
+func newStopLossStopStrategy(config StopStrategyConfig, _ Request) (StopStrategy, error) {
+	if config.Amount <= 0 {
+		return nil, errors.New("non-positive stop-loss amount")
+	}
+	return StopStrategyFunc(func(stopContext StopContext) bool {
+		return stopContext.TopTierPulls == 0 && stopContext.MoneySpent >= config.Amount
+	}), nil
+}
+
+func newUntilPityStopStrategy(_ StopStrategyConfig, request Request) (StopStrategy, error) {
+	if !request.Policies.Pity {
+		return nil, errors.New("until-pity stop strategy without pity")
+	}
+	return StopStrategyFunc(func(stopContext StopContext) bool {
+		return stopContext.PityItemObtained
+	}), nil
+}
+
+func init() {
+	RegisterStopStrategy(StopStrategyFirstTopTier, newFirstTopTierStopStrategy)
+	RegisterStopStrategy(StopStrategyStopLoss, newStopLossStopStrategy)
+	RegisterStopStrategy(StopStrategyUntilPity, newUntilPityStopStrategy)
+}
@@ -3,18 +3,21 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"gacha-simulator/gacha"
 	"gacha-simulator/model"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type GameTitleInput struct {
-	Slug         string                      `json:"slug"`
-	ImageURL     string                      `json:"imageUrl"`
-	DisplayOrder uint                        `json:"displayOrder"`
-	Translations []GameTitleTranslationInput `json:"translations"`
+	Slug                 string                      `json:"slug"`
+	ImageURL             string                      `json:"imageUrl"`
+	DisplayOrder         uint                        `json:"displayOrder"`
+	ResultRetentionHours *int                        `json:"resultRetentionHours"`
+	Translations         []GameTitleTranslationInput `json:"translations"`
 }
 
 type GameTitleTranslationInput struct {
@@ -25,10 +28,11 @@ type GameTitleTranslationInput struct {
 }
 
 type TierInput struct {
-	Key          string                 `json:"key"`
-	Ratio        int                    `json:"ratio"`
-	ImageURL     string                 `json:"imageUrl"`
-	Translations []TierTranslationInput `json:"translations"`
+	Key             string                 `json:"key"`
+	Ratio           int                    `json:"ratio"`
+	ConversionValue float64                `json:"conversionValue"`
+	ImageURL        string                 `json:"imageUrl"`
+	Translations    []TierTranslationInput `json:"translations"`
 }
 
 type TierTranslationInput struct {
@@ -41,6 +45,7 @@ type ItemInput struct {
 	TierKey      string                 `json:"tierKey"`
 	Key          *string                `json:"key"`
 	Ratio        *int                   `json:"ratio"`
+	ShopPrice    *float64               `json:"shopPrice"`
 	ImageURL     string                 `json:"imageUrl"`
 	Translations []ItemTranslationInput `json:"translations"`
 }
@@ -85,14 +90,26 @@ type KeyNumberTuple struct {
 }
 
 type PlanInput struct {
-	Key                  *string                `json:"key"`
-	Budget               float64                `json:"budget"`
-	MaxConsecutiveGachas int                    `json:"maxConsecutiveGachas"`
-	ItemGoals            bool                   `json:"itemGoals"`
-	WantedItems          []KeyNumberTuple       `json:"wantedItems"`
-	TierGoals            bool                   `json:"tierGoals"`
-	WantedTiers          []KeyNumberTuple       `json:"wantedTiers"`
-	Translations         []PlanTranslationInput `json:"translations"`
+	Key                  *string                    `json:"key"`
+	Budget               float64                    `json:"budget"`
+	MaxConsecutiveGachas int                        `json:"maxConsecutiveGachas"`
+	ItemGoals            bool                       `json:"itemGoals"`
+	WantedItems          []KeyNumberTuple           `json:"wantedItems"`
+	TierGoals            bool                       `json:"tierGoals"`
+	WantedTiers          []KeyNumberTuple           `json:"wantedTiers"`
+	Goal                 *GoalInput                 `json:"goal"`
+	StopStrategies       []gacha.StopStrategyConfig `json:"stopStrategies"`
+	UseShop              bool                       `json:"useShop"`
+	Translations         []PlanTranslationInput     `json:"translations"`
+}
+
+type GoalInput struct {
+	Type     string      `json:"type"`
+	ItemKey  string      `json:"itemKey"`
+	TierKey  string      `json:"tierKey"`
+	ItemKeys []string    `json:"itemKeys"`
+	Number   int         `json:"number"`
+	Children []GoalInput `json:"children"`
 }
 
 type PlanTranslationInput struct {
@@ -104,6 +121,8 @@ type PresetInput struct {
 	PricingKey   *string                  `json:"pricingKey"`
 	PoliciesKey  *string                  `json:"policiesKey"`
 	PlanKey      *string                  `json:"planKey"`
+	StartTime    *time.Time               `json:"startTime"`
+	EndTime      *time.Time               `json:"endTime"`
 	Translations []PresetTranslationInput `json:"translations"`
 }
 
@@ -113,6 +132,34 @@ type PresetTranslationInput struct {
 	Description string `json:"description"`
 }
 
+type BannerInput struct {
+	RestrictItems bool                     `json:"restrictItems"`
+	StartTime     *time.Time               `json:"startTime"`
+	EndTime       *time.Time               `json:"endTime"`
+	Tiers         []BannerTierInput        `json:"tiers"`
+	Items         []BannerItemInput        `json:"items"`
+	Translations  []BannerTranslationInput `json:"translations"`
+}
+
+type BannerTierInput struct {
+	TierKey string `json:"tierKey"`
+	Ratio   *int   `json:"ratio"`
+}
+
+type BannerItemInput struct {
+	ItemKey  string `json:"itemKey"`
+	Ratio    *int   `json:"ratio"`
+	Excluded bool   `json:"excluded"`
+	Featured bool   `json:"featured"`
+	Boost    int    `json:"boost"`
+}
+
+type BannerTranslationInput struct {
+	Language    string `json:"language"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
 type GameTitleBulk struct {
 	GameTitle GameTitleInput  `json:"gameTitle"`
 	Tiers     []TierInput     `json:"tiers"`
@@ -121,6 +168,7 @@ type GameTitleBulk struct {
 	Policies  []PoliciesInput `json:"policies"`
 	Plans     []PlanInput     `json:"plans"`
 	Presets   []PresetInput   `json:"presets"`
+	Banners   []BannerInput   `json:"banners"`
 }
 
 type GameTitleBulkRequest struct {
@@ -151,55 +199,11 @@ func PostGameTitlesBulk(ctx *gin.Context) {
 		Errors:  make([]IndexErrorTuple, 0),
 	}
 	for i, gameTitleBulk := range gameTitleBulkRequest.GameTitleBulks {
-		if err := model.DB.Transaction(func(tx *gorm.DB) error {
-			gameTitleModel := mapGameTitleModel(gameTitleBulk.GameTitle)
-			if err := tx.Create(gameTitleModel).Error; err != nil {
-				return err
-			}
-			gameTitleID := gameTitleModel.ID
-			tierKeyToModel := make(map[string]*model.Tier)
-			tiersModel := mapTiersModel(gameTitleBulk.Tiers, gameTitleID, tierKeyToModel)
-			if err := tx.Create(tiersModel).Error; err != nil {
-				return err
-			}
-			itemKeyToModel := make(map[string]*model.Item)
-			itemsModel, err := mapItemsModel(gameTitleBulk.Items, tierKeyToModel, itemKeyToModel)
-			if err != nil {
-				return err
-			}
-			if err := tx.Create(itemsModel).Error; err != nil {
-				return err
-			}
-			pricingKeyToModel := make(map[string]*model.Pricing)
-			pricingsModel := mapPricingsModel(gameTitleBulk.Pricings, gameTitleID, pricingKeyToModel)
-			if err := tx.Create(pricingsModel).Error; err != nil {
-				return err
-			}
-			policiesKeyToModel := make(map[string]*model.Policies)
-			policiesModel, err := mapPoliciesModel(gameTitleBulk.Policies, gameTitleID, itemKeyToModel, policiesKeyToModel)
-			if err != nil {
-				return err
-			}
-			if err := tx.Create(policiesModel).Error; err != nil {
-				return err
-			}
-			planKeyToModel := make(map[string]*model.Plan)
-			plansModel, err := mapPlansModel(gameTitleBulk.Plans, gameTitleID, tierKeyToModel, itemKeyToModel, planKeyToModel)
-			if err != nil {
-				return err
-			}
-			if err := tx.Create(plansModel).Error; err != nil {
-				return err
-			}
-			presetsModel, err := mapPresetsModel(gameTitleBulk.Presets, gameTitleID, pricingKeyToModel, policiesKeyToModel, planKeyToModel)
-			if err != nil {
-				return err
-			}
-			if err := tx.Create(presetsModel).Error; err != nil {
-				return err
-			}
-			return nil
-		}); err != nil {
+		err := model.DB.Transaction(func(tx *gorm.DB) error {
+			return createGameTitleBulk(tx, gameTitleBulk)
+		})
+		addAuditTarget(ctx, gameTitleBulk.GameTitle.Slug, err)
+		if err != nil {
 			response.Failure++
 			response.Errors = append(response.Errors, IndexErrorTuple{
 				Index: i,
@@ -221,14 +225,120 @@ func DeleteGameTitle(ctx *gin.Context) {
 	ctx.Status(http.StatusNoContent)
 }
 
-func mapGameTitleModel(gameTitleInput GameTitleInput) *model.GameTitle {
+func RestoreGameTitle(ctx *gin.Context) {
+	gameTitleSlug := ctx.Param("gameTitleSlug")
+	var count int64
+	if err := model.DB.
+		Model(&model.GameTitle{}).
+		Where("slug = ?", gameTitleSlug).
+		Count(&count).
+		Error; err != nil {
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+	if count > 0 {
+		ctx.Status(http.StatusConflict)
+		return
+	}
+	tx := model.DB.
+		Unscoped().
+		Model(&model.GameTitle{}).
+		Where(
+			"id = (?)",
+			model.DB.
+				Unscoped().
+				Model(&model.GameTitle{}).
+				Select("id").
+				Where("slug = ? AND deleted_at IS NOT NULL", gameTitleSlug).
+				Order("deleted_at DESC").
+				Limit(1),
+		).
+		Update("deleted_at", nil)
+	if err := tx.Error; err != nil {
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+	if tx.RowsAffected == 0 {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func createGameTitleBulk(tx *gorm.DB, gameTitleBulk GameTitleBulk) error {
+	gameTitleModel, err := mapGameTitleModel(gameTitleBulk.GameTitle)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(gameTitleModel).Error; err != nil {
+		return err
+	}
+	gameTitleID := gameTitleModel.ID
+	tierKeyToModel := make(map[string]*model.Tier)
+	tiersModel := mapTiersModel(gameTitleBulk.Tiers, gameTitleID, tierKeyToModel)
+	if err := tx.Create(tiersModel).Error; err != nil {
+		return err
+	}
+	itemKeyToModel := make(map[string]*model.Item)
+	itemsModel, err := mapItemsModel(gameTitleBulk.Items, tierKeyToModel, itemKeyToModel)
+	if err != nil {
+		return err
+	}
+	if err := tx.CreateInBatches(itemsModel, BulkImportBatchSize).Error; err != nil {
+		return err
+	}
+	pricingKeyToModel := make(map[string]*model.Pricing)
+	pricingsModel := mapPricingsModel(gameTitleBulk.Pricings, gameTitleID, pricingKeyToModel)
+	if err := tx.Create(pricingsModel).Error; err != nil {
+		return err
+	}
+	policiesKeyToModel := make(map[string]*model.Policies)
+	policiesModel, err := mapPoliciesModel(gameTitleBulk.Policies, gameTitleID, itemKeyToModel, policiesKeyToModel)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(policiesModel).Error; err != nil {
+		return err
+	}
+	planKeyToModel := make(map[string]*model.Plan)
+	plansModel, err := mapPlansModel(gameTitleBulk.Plans, gameTitleID, tierKeyToModel, itemKeyToModel, planKeyToModel)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(plansModel).Error; err != nil {
+		return err
+	}
+	presetsModel, err := mapPresetsModel(gameTitleBulk.Presets, gameTitleID, pricingKeyToModel, policiesKeyToModel, planKeyToModel)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(presetsModel).Error; err != nil {
+		return err
+	}
+	bannersModel, err := mapBannersModel(gameTitleBulk.Banners, gameTitleID, tierKeyToModel, itemKeyToModel)
+	if err != nil {
+		return err
+	}
+	if len(bannersModel) > 0 {
+		if err := tx.Create(bannersModel).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mapGameTitleModel(gameTitleInput GameTitleInput) (*model.GameTitle, error) {
+	if gameTitleInput.ResultRetentionHours != nil && *gameTitleInput.ResultRetentionHours <= 0 {
+		return nil, errors.New("non-positive result retention hours")
+	}
 	translations := mapGameTitleTranslationsModel(gameTitleInput.Translations)
 	return &model.GameTitle{
-		Slug:         gameTitleInput.Slug,
-		ImageURL:     gameTitleInput.ImageURL,
-		DisplayOrder: gameTitleInput.DisplayOrder,
-		Translations: translations,
-	}
+		Slug:                 gameTitleInput.Slug,
+		ImageURL:             gameTitleInput.ImageURL,
+		DisplayOrder:         gameTitleInput.DisplayOrder,
+		ResultRetentionHours: gameTitleInput.ResultRetentionHours,
+		Translations:         translations,
+	}, nil
 }
 
 func mapTiersModel(
@@ -251,10 +361,11 @@ func mapTierModel(
 ) *model.Tier {
 	translations := mapTierTranslationsModel(tierInput.Translations)
 	tierModel := model.Tier{
-		Ratio:        tierInput.Ratio,
-		GameTitleID:  gameTitleID,
-		ImageURL:     tierInput.ImageURL,
-		Translations: translations,
+		Ratio:           tierInput.Ratio,
+		ConversionValue: tierInput.ConversionValue,
+		GameTitleID:     gameTitleID,
+		ImageURL:        tierInput.ImageURL,
+		Translations:    translations,
 	}
 	tierKeyToModel[tierInput.Key] = &tierModel
 	return &tierModel
@@ -286,6 +397,10 @@ func mapItemModel(
 		ImageURL:     itemInput.ImageURL,
 		Translations: translations,
 	}
+	if itemInput.ShopPrice != nil && *itemInput.ShopPrice <= 0 {
+		return nil, errors.New("non-positive item ShopPrice")
+	}
+	itemModel.ShopPrice = itemInput.ShopPrice
 	if tier, ok := tierKeyToModel[itemInput.TierKey]; ok {
 		itemModel.TierID = tier.ID
 	} else {
@@ -411,6 +526,7 @@ func mapPlanModel(
 		WantedItemsJSON:      nil,
 		TierGoals:            planInput.TierGoals,
 		WantedTiersJSON:      nil,
+		UseShop:              planInput.UseShop,
 		GameTitleID:          gameTitleID,
 		Translations:         translations,
 	}
@@ -446,12 +562,82 @@ func mapPlanModel(
 		}
 		planModel.WantedTiersJSON = wantedTiersJSON
 	}
+	if planInput.Goal != nil {
+		goal, err := mapGoal(*planInput.Goal, tierKeyToModel, itemKeyToModel)
+		if err != nil {
+			return nil, err
+		}
+		if err := goal.Validate(); err != nil {
+			return nil, err
+		}
+		goalJSON, err := json.Marshal(goal)
+		if err != nil {
+			return nil, err
+		}
+		planModel.GoalJSON = goalJSON
+	}
+	if len(planInput.StopStrategies) > 0 {
+		for _, stopStrategy := range planInput.StopStrategies {
+			if err := stopStrategy.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		stopStrategiesJSON, err := json.Marshal(planInput.StopStrategies)
+		if err != nil {
+			return nil, err
+		}
+		planModel.StopStrategiesJSON = stopStrategiesJSON
+	}
 	if planInput.Key != nil && *planInput.Key != "" {
 		planKeyToModel[*planInput.Key] = &planModel
 	}
 	return &planModel, nil
 }
 
+func mapGoal(
+	goalInput GoalInput,
+	tierKeyToModel map[string]*model.Tier,
+	itemKeyToModel map[string]*model.Item,
+) (*gacha.Goal, error) {
+	goal := gacha.Goal{
+		Type:   goalInput.Type,
+		Number: goalInput.Number,
+	}
+	switch goalInput.Type {
+	case gacha.GoalTypeAll, gacha.GoalTypeAny:
+		for _, childInput := range goalInput.Children {
+			child, err := mapGoal(childInput, tierKeyToModel, itemKeyToModel)
+			if err != nil {
+				return nil, err
+			}
+			goal.Children = append(goal.Children, *child)
+		}
+	case gacha.GoalTypeItem:
+		itemModel, ok := itemKeyToModel[goalInput.ItemKey]
+		if !ok {
+			return nil, errors.New("invalid Goal ItemKey: " + goalInput.ItemKey)
+		}
+		goal.ItemID = itemModel.ID
+	case gacha.GoalTypeTier, gacha.GoalTypeDistinct:
+		tierModel, ok := tierKeyToModel[goalInput.TierKey]
+		if !ok {
+			return nil, errors.New("invalid Goal TierKey: " + goalInput.TierKey)
+		}
+		goal.TierID = tierModel.ID
+	case gacha.GoalTypeSet, gacha.GoalTypeGroup:
+		for _, itemKey := range goalInput.ItemKeys {
+			itemModel, ok := itemKeyToModel[itemKey]
+			if !ok {
+				return nil, errors.New("invalid Goal ItemKey: " + itemKey)
+			}
+			goal.ItemIDs = append(goal.ItemIDs, itemModel.ID)
+		}
+	default:
+		return nil, errors.New("invalid Goal Type: " + goalInput.Type)
+	}
+	return &goal, nil
+}
+
 func mapPresetsModel(
 	presetsInput []PresetInput,
 	gameTitleID uint,
@@ -478,8 +664,13 @@ func mapPresetModel(
 	planKeyToModel map[string]*model.Plan,
 ) (*model.Preset, error) {
 	translations := mapPresetTranslationsModel(presetInput.Translations)
+	if presetInput.StartTime != nil && presetInput.EndTime != nil && !presetInput.EndTime.After(*presetInput.StartTime) {
+		return nil, errors.New("invalid schedule: endTime not after startTime")
+	}
 	presetModel := model.Preset{
 		GameTitleID:  gameTitleID,
+		StartTime:    presetInput.StartTime,
+		EndTime:      presetInput.EndTime,
 		Translations: translations,
 	}
 	if presetInput.PricingKey != nil && *presetInput.PricingKey != "" {
@@ -506,6 +697,74 @@ func mapPresetModel(
 	return &presetModel, nil
 }
 
+func mapBannersModel(
+	bannersInput []BannerInput,
+	gameTitleID uint,
+	tierKeyToModel map[string]*model.Tier,
+	itemKeyToModel map[string]*model.Item,
+) ([]*model.Banner, error) {
+	bannersModel := make([]*model.Banner, 0)
+	for i := 0; i < len(bannersInput); i++ {
+		bannerModel, err := mapBannerModel(bannersInput[i], gameTitleID, tierKeyToModel, itemKeyToModel)
+		if err != nil {
+			return nil, err
+		}
+		bannersModel = append(bannersModel, bannerModel)
+	}
+	return bannersModel, nil
+}
+
+func mapBannerModel(
+	bannerInput BannerInput,
+	gameTitleID uint,
+	tierKeyToModel map[string]*model.Tier,
+	itemKeyToModel map[string]*model.Item,
+) (*model.Banner, error) {
+	if bannerInput.StartTime != nil && bannerInput.EndTime != nil && !bannerInput.EndTime.After(*bannerInput.StartTime) {
+		return nil, errors.New("invalid schedule: endTime not after startTime")
+	}
+	translations := mapBannerTranslationsModel(bannerInput.Translations)
+	bannerModel := model.Banner{
+		GameTitleID:   gameTitleID,
+		RestrictItems: bannerInput.RestrictItems,
+		StartTime:     bannerInput.StartTime,
+		EndTime:       bannerInput.EndTime,
+		Tiers:         make([]model.BannerTier, 0),
+		Items:         make([]model.BannerItem, 0),
+		Translations:  translations,
+	}
+	for _, bannerTierInput := range bannerInput.Tiers {
+		tierModel, ok := tierKeyToModel[bannerTierInput.TierKey]
+		if !ok {
+			return nil, errors.New("invalid Banner TierKey: " + bannerTierInput.TierKey)
+		}
+		if bannerTierInput.Ratio != nil && *bannerTierInput.Ratio < 0 {
+			return nil, errors.New("negative Banner tier ratio: " + bannerTierInput.TierKey)
+		}
+		bannerModel.Tiers = append(bannerModel.Tiers, model.BannerTier{
+			TierID: tierModel.ID,
+			Ratio:  bannerTierInput.Ratio,
+		})
+	}
+	for _, bannerItemInput := range bannerInput.Items {
+		itemModel, ok := itemKeyToModel[bannerItemInput.ItemKey]
+		if !ok {
+			return nil, errors.New("invalid Banner ItemKey: " + bannerItemInput.ItemKey)
+		}
+		if bannerItemInput.Ratio != nil && *bannerItemInput.Ratio < 0 {
+			return nil, errors.New("negative Banner item ratio: " + bannerItemInput.ItemKey)
+		}
+		bannerModel.Items = append(bannerModel.Items, model.BannerItem{
+			ItemID:   itemModel.ID,
+			Ratio:    bannerItemInput.Ratio,
+			Excluded: bannerItemInput.Excluded,
+			Featured: bannerItemInput.Featured,
+			Boost:    bannerItemInput.Boost,
+		})
+	}
+	return &bannerModel, nil
+}
+
 func mapGameTitleTranslationsModel(translationsInput []GameTitleTranslationInput) []model.GameTitleTranslation {
 	translations := make([]model.GameTitleTranslation, 0)
 	for i := 0; i < len(translationsInput); i++ {
@@ -623,3 +882,20 @@ func mapPresetTranslationModel(translationInput PresetTranslationInput) *model.P
 		Description: translationInput.Description,
 	}
 }
+
+func mapBannerTranslationsModel(translationsInput []BannerTranslationInput) []model.BannerTranslation {
+	translations := make([]model.BannerTranslation, 0)
+	for i := 0; i < len(translationsInput); i++ {
+		translation := mapBannerTranslationModel(translationsInput[i])
+		translations = append(translations, *translation)
+	}
+	return translations
+}
+
+func mapBannerTranslationModel(translationInput BannerTranslationInput) *model.BannerTranslation {
+	return &model.BannerTranslation{
+		Language:    translationInput.Language,
+		Name:        translationInput.Name,
+		Description: translationInput.Description,
+	}
+}
@@ -0,0 +1,380 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gacha-simulator/model"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	CSVColumnKey             = "key"
+	CSVColumnTierKey         = "tierKey"
+	CSVColumnRatio           = "ratio"
+	CSVColumnImageURL        = "imageUrl"
+	CSVColumnName            = "name"
+	CSVColumnShortName       = "shortName"
+	CSVColumnShortNameAlt    = "shortNameAlt"
+	CSVColumnConversionValue = "conversionValue"
+	CSVColumnShopPrice       = "shopPrice"
+)
+
+type RowErrorTuple struct {
+	File  string `json:"file"`
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type GameTitleCSVErrorResponse struct {
+	Errors []RowErrorTuple `json:"errors"`
+}
+
+type csvTable struct {
+	name    string
+	header  map[string]int
+	columns []string
+	rows    [][]string
+	lines   []int
+}
+
+func PostGameTitlesBulkCSV(ctx *gin.Context) {
+	var gameTitleBulk GameTitleBulk
+	gameTitleBulkJSON := ctx.PostForm("gameTitleBulk")
+	if gameTitleBulkJSON == "" {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal([]byte(gameTitleBulkJSON), &gameTitleBulk); err != nil {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	if gameTitleBulk.GameTitle.Slug == "" {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	rowErrors := make([]RowErrorTuple, 0)
+	tiersTable, rowError, err := readCSVFormFile(ctx, "tiers")
+	if err != nil {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	if rowError != nil {
+		rowErrors = append(rowErrors, *rowError)
+	}
+	itemsTable, rowError, err := readCSVFormFile(ctx, "items")
+	if err != nil {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	if rowError != nil {
+		rowErrors = append(rowErrors, *rowError)
+	}
+	if tiersTable != nil {
+		tiersInput, tierRowErrors := mapTiersInputFromCSV(*tiersTable)
+		gameTitleBulk.Tiers = append(gameTitleBulk.Tiers, tiersInput...)
+		rowErrors = append(rowErrors, tierRowErrors...)
+	}
+	if itemsTable != nil {
+		tierKeys := make(map[string]bool)
+		for _, tierInput := range gameTitleBulk.Tiers {
+			tierKeys[tierInput.Key] = true
+		}
+		itemsInput, itemRowErrors := mapItemsInputFromCSV(*itemsTable, tierKeys)
+		gameTitleBulk.Items = append(gameTitleBulk.Items, itemsInput...)
+		rowErrors = append(rowErrors, itemRowErrors...)
+	}
+	if len(rowErrors) > 0 {
+		ctx.JSON(http.StatusBadRequest, &GameTitleCSVErrorResponse{
+			Errors: rowErrors,
+		})
+		return
+	}
+	response := GameTitleBulkResponse{
+		Success: 0,
+		Failure: 0,
+		Errors:  make([]IndexErrorTuple, 0),
+	}
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		return createGameTitleBulk(tx, gameTitleBulk)
+	})
+	addAuditTarget(ctx, gameTitleBulk.GameTitle.Slug, err)
+	if err != nil {
+		response.Failure++
+		response.Errors = append(response.Errors, IndexErrorTuple{
+			Index: 0,
+			Error: err.Error(),
+		})
+	} else {
+		response.Success++
+	}
+	ctx.JSON(http.StatusOK, &response)
+}
+
+func readCSVFormFile(ctx *gin.Context, name string) (*csvTable, *RowErrorTuple, error) {
+	fileHeader, err := ctx.FormFile(name)
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	table, rowError := readCSVTable(name, file)
+	return table, rowError, nil
+}
+
+func readCSVTable(name string, file io.Reader) (*csvTable, *RowErrorTuple) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	columns, err := reader.Read()
+	if err != nil {
+		return nil, mapCSVRowError(name, 1, err)
+	}
+	table := csvTable{
+		name:    name,
+		header:  make(map[string]int),
+		columns: columns,
+	}
+	for i, column := range columns {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if _, ok := table.header[column]; ok {
+			return nil, &RowErrorTuple{File: name, Row: 1, Error: "duplicate column: " + column}
+		}
+		table.header[column] = i
+		table.columns[i] = column
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, mapCSVRowError(name, 0, err)
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlankCSVRow(row) {
+			continue
+		}
+		table.rows = append(table.rows, row)
+		table.lines = append(table.lines, line)
+	}
+	return &table, nil
+}
+
+func mapCSVRowError(name string, line int, err error) *RowErrorTuple {
+	var parseError *csv.ParseError
+	if errors.As(err, &parseError) {
+		line = parseError.StartLine
+		err = parseError.Err
+	}
+	return &RowErrorTuple{File: name, Row: line, Error: err.Error()}
+}
+
+func isBlankCSVRow(row []string) bool {
+	for _, field := range row {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (table csvTable) get(row []string, column string) string {
+	if i, ok := table.header[column]; ok {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+func (table csvTable) languages() ([]string, error) {
+	languages := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, column := range table.columns {
+		field, language, found := strings.Cut(column, ".")
+		if !found {
+			continue
+		}
+		if field != CSVColumnName && field != CSVColumnShortName && field != CSVColumnShortNameAlt {
+			return nil, errors.New("invalid column: " + column)
+		}
+		if language == "" {
+			return nil, errors.New("empty language in column: " + column)
+		}
+		if !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	return languages, nil
+}
+
+func (table csvTable) validateColumns(allowed ...string) error {
+	allowedMap := make(map[string]bool)
+	for _, column := range allowed {
+		allowedMap[column] = true
+	}
+	for _, column := range table.columns {
+		if strings.Contains(column, ".") {
+			continue
+		}
+		if !allowedMap[column] {
+			return errors.New("invalid column: " + column)
+		}
+	}
+	return nil
+}
+
+func mapTiersInputFromCSV(table csvTable) ([]TierInput, []RowErrorTuple) {
+	tiersInput := make([]TierInput, 0)
+	rowErrors := make([]RowErrorTuple, 0)
+	if err := table.validateColumns(CSVColumnKey, CSVColumnRatio, CSVColumnConversionValue, CSVColumnImageURL); err != nil {
+		return nil, append(rowErrors, RowErrorTuple{File: table.name, Row: 1, Error: err.Error()})
+	}
+	languages, err := table.languages()
+	if err != nil {
+		return nil, append(rowErrors, RowErrorTuple{File: table.name, Row: 1, Error: err.Error()})
+	}
+	keys := make(map[string]int)
+	for i, row := range table.rows {
+		line := table.lines[i]
+		key := table.get(row, CSVColumnKey)
+		if key == "" {
+			rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "empty key"})
+			continue
+		}
+		if firstLine, ok := keys[key]; ok {
+			rowErrors = append(rowErrors, RowErrorTuple{
+				File:  table.name,
+				Row:   line,
+				Error: fmt.Sprintf("duplicate key %s (first on row %d)", key, firstLine),
+			})
+			continue
+		}
+		keys[key] = line
+		ratio, err := strconv.Atoi(table.get(row, CSVColumnRatio))
+		if err != nil || ratio < 0 {
+			rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "invalid ratio"})
+			continue
+		}
+		conversionValue := 0.0
+		if conversionValueStr := table.get(row, CSVColumnConversionValue); conversionValueStr != "" {
+			conversionValue, err = strconv.ParseFloat(conversionValueStr, 64)
+			if err != nil || conversionValue < 0 {
+				rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "invalid conversion value"})
+				continue
+			}
+		}
+		translations := make([]TierTranslationInput, 0)
+		for _, language := range languages {
+			name := table.get(row, CSVColumnName+"."+language)
+			shortName := table.get(row, CSVColumnShortName+"."+language)
+			if name == "" && shortName == "" {
+				continue
+			}
+			translations = append(translations, TierTranslationInput{
+				Language:  language,
+				Name:      name,
+				ShortName: shortName,
+			})
+		}
+		if len(translations) == 0 {
+			rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "translations empty"})
+			continue
+		}
+		tiersInput = append(tiersInput, TierInput{
+			Key:             key,
+			Ratio:           ratio,
+			ConversionValue: conversionValue,
+			ImageURL:        table.get(row, CSVColumnImageURL),
+			Translations:    translations,
+		})
+	}
+	return tiersInput, rowErrors
+}
+
+func mapItemsInputFromCSV(table csvTable, tierKeys map[string]bool) ([]ItemInput, []RowErrorTuple) {
+	itemsInput := make([]ItemInput, 0)
+	rowErrors := make([]RowErrorTuple, 0)
+	if err := table.validateColumns(CSVColumnKey, CSVColumnTierKey, CSVColumnRatio, CSVColumnShopPrice, CSVColumnImageURL); err != nil {
+		return nil, append(rowErrors, RowErrorTuple{File: table.name, Row: 1, Error: err.Error()})
+	}
+	languages, err := table.languages()
+	if err != nil {
+		return nil, append(rowErrors, RowErrorTuple{File: table.name, Row: 1, Error: err.Error()})
+	}
+	keys := make(map[string]int)
+	for i, row := range table.rows {
+		line := table.lines[i]
+		tierKey := table.get(row, CSVColumnTierKey)
+		if !tierKeys[tierKey] {
+			rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "invalid TierKey: " + tierKey})
+			continue
+		}
+		itemInput := ItemInput{
+			TierKey:  tierKey,
+			ImageURL: table.get(row, CSVColumnImageURL),
+		}
+		if key := table.get(row, CSVColumnKey); key != "" {
+			if firstLine, ok := keys[key]; ok {
+				rowErrors = append(rowErrors, RowErrorTuple{
+					File:  table.name,
+					Row:   line,
+					Error: fmt.Sprintf("duplicate key %s (first on row %d)", key, firstLine),
+				})
+				continue
+			}
+			keys[key] = line
+			itemInput.Key = &key
+		}
+		if ratioStr := table.get(row, CSVColumnRatio); ratioStr != "" {
+			ratio, err := strconv.Atoi(ratioStr)
+			if err != nil || ratio < 0 {
+				rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "invalid ratio"})
+				continue
+			}
+			itemInput.Ratio = &ratio
+		}
+		if shopPriceStr := table.get(row, CSVColumnShopPrice); shopPriceStr != "" {
+			shopPrice, err := strconv.ParseFloat(shopPriceStr, 64)
+			if err != nil || shopPrice <= 0 {
+				rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "invalid shop price"})
+				continue
+			}
+			itemInput.ShopPrice = &shopPrice
+		}
+		translations := make([]ItemTranslationInput, 0)
+		for _, language := range languages {
+			name := table.get(row, CSVColumnName+"."+language)
+			shortName := table.get(row, CSVColumnShortName+"."+language)
+			shortNameAlt := table.get(row, CSVColumnShortNameAlt+"."+language)
+			if name == "" && shortName == "" && shortNameAlt == "" {
+				continue
+			}
+			translations = append(translations, ItemTranslationInput{
+				Language:     language,
+				Name:         name,
+				ShortName:    shortName,
+				ShortNameAlt: shortNameAlt,
+			})
+		}
+		if len(translations) == 0 {
+			rowErrors = append(rowErrors, RowErrorTuple{File: table.name, Row: line, Error: "translations empty"})
+			continue
+		}
+		itemInput.Translations = translations
+		itemsInput = append(itemsInput, itemInput)
+	}
+	return itemsInput, rowErrors
+}
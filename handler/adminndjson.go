@@ -0,0 +1,381 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gacha-simulator/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const BulkImportBatchSize = 500
+const MaxBulkRecordSize = 8 << 20
+
+const (
+	BulkRecordGameTitle = "gameTitle"
+	BulkRecordTier      = "tier"
+	BulkRecordItem      = "item"
+	BulkRecordPricing   = "pricing"
+	BulkRecordPolicies  = "policies"
+	BulkRecordPlan      = "plan"
+	BulkRecordPreset    = "preset"
+	BulkRecordBanner    = "banner"
+)
+
+type GameTitleBulkRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type GameTitleBulkProgress struct {
+	Index    int    `json:"index"`
+	Slug     string `json:"slug"`
+	Tiers    int    `json:"tiers"`
+	Items    int    `json:"items"`
+	Pricings int    `json:"pricings"`
+	Policies int    `json:"policies"`
+	Plans    int    `json:"plans"`
+	Presets  int    `json:"presets"`
+	Banners  int    `json:"banners"`
+	Done     bool   `json:"done"`
+}
+
+type GameTitleBulkStreamLine struct {
+	Progress *GameTitleBulkProgress `json:"progress,omitempty"`
+	Summary  *GameTitleBulkResponse `json:"summary,omitempty"`
+}
+
+type gameTitleBulkStream struct {
+	ctx                *gin.Context
+	response           GameTitleBulkResponse
+	index              int
+	active             bool
+	tx                 *gorm.DB
+	progress           GameTitleBulkProgress
+	gameTitleID        uint
+	tierKeyToModel     map[string]*model.Tier
+	itemKeyToModel     map[string]*model.Item
+	pricingKeyToModel  map[string]*model.Pricing
+	policiesKeyToModel map[string]*model.Policies
+	planKeyToModel     map[string]*model.Plan
+	pendingType        string
+	pendingFirstLine   int
+	pendingLastLine    int
+	tiers              []*model.Tier
+	items              []*model.Item
+	pricings           []*model.Pricing
+	policies           []*model.Policies
+	plans              []*model.Plan
+	presets            []*model.Preset
+	banners            []*model.Banner
+}
+
+func PostGameTitlesBulkStream(ctx *gin.Context) {
+	scanner := bufio.NewScanner(ctx.Request.Body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MaxBulkRecordSize)
+	stream := &gameTitleBulkStream{
+		ctx: ctx,
+		response: GameTitleBulkResponse{
+			Success: 0,
+			Failure: 0,
+			Errors:  make([]IndexErrorTuple, 0),
+		},
+		index: -1,
+	}
+	started := false
+	line := 0
+	for scanner.Scan() {
+		data := scanner.Bytes()
+		line++
+		if len(bytes.TrimSpace(data)) > 0 {
+			var record GameTitleBulkRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				if !started {
+					ctx.Status(http.StatusBadRequest)
+					return
+				}
+				stream.fail(fmt.Errorf("line %d: %w", line, err))
+			} else if record.Type == BulkRecordGameTitle {
+				if !started {
+					started = true
+					ctx.Header("Content-Type", "application/x-ndjson")
+					ctx.Status(http.StatusOK)
+				}
+				stream.finish()
+				stream.begin(record.Data, line)
+			} else if !started {
+				ctx.Status(http.StatusBadRequest)
+				return
+			} else if err := stream.add(record, line); err != nil {
+				stream.fail(err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if !started {
+			ctx.Status(http.StatusBadRequest)
+			return
+		}
+		stream.fail(fmt.Errorf("line %d: %w", line+1, err))
+	}
+	if !started {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	stream.finish()
+	stream.write(GameTitleBulkStreamLine{Summary: &stream.response})
+}
+
+func (stream *gameTitleBulkStream) begin(data json.RawMessage, line int) {
+	stream.index++
+	stream.active = true
+	stream.progress = GameTitleBulkProgress{Index: stream.index}
+	stream.tierKeyToModel = make(map[string]*model.Tier)
+	stream.itemKeyToModel = make(map[string]*model.Item)
+	stream.pricingKeyToModel = make(map[string]*model.Pricing)
+	stream.policiesKeyToModel = make(map[string]*model.Policies)
+	stream.planKeyToModel = make(map[string]*model.Plan)
+	var gameTitleInput GameTitleInput
+	if err := json.Unmarshal(data, &gameTitleInput); err != nil {
+		stream.fail(fmt.Errorf("line %d: %w", line, err))
+		return
+	}
+	stream.progress.Slug = gameTitleInput.Slug
+	gameTitleModel, err := mapGameTitleModel(gameTitleInput)
+	if err != nil {
+		stream.fail(fmt.Errorf("line %d: %w", line, err))
+		return
+	}
+	stream.tx = model.DB.Begin()
+	if err := stream.tx.Create(gameTitleModel).Error; err != nil {
+		stream.fail(fmt.Errorf("line %d: %w", line, err))
+		return
+	}
+	stream.gameTitleID = gameTitleModel.ID
+}
+
+func (stream *gameTitleBulkStream) add(record GameTitleBulkRecord, line int) error {
+	if !stream.active {
+		return nil
+	}
+	if stream.pendingType != "" && stream.pendingType != record.Type {
+		if err := stream.flush(); err != nil {
+			return err
+		}
+	}
+	pending, err := stream.mapRecord(record)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+	if stream.pendingType == "" {
+		stream.pendingFirstLine = line
+	}
+	stream.pendingType = record.Type
+	stream.pendingLastLine = line
+	if pending >= BulkImportBatchSize {
+		return stream.flush()
+	}
+	return nil
+}
+
+func (stream *gameTitleBulkStream) mapRecord(record GameTitleBulkRecord) (int, error) {
+	switch record.Type {
+	case BulkRecordTier:
+		var tierInput TierInput
+		if err := json.Unmarshal(record.Data, &tierInput); err != nil {
+			return 0, err
+		}
+		tierModel := mapTierModel(tierInput, stream.gameTitleID, stream.tierKeyToModel)
+		stream.tiers = append(stream.tiers, tierModel)
+		return len(stream.tiers), nil
+	case BulkRecordItem:
+		var itemInput ItemInput
+		if err := json.Unmarshal(record.Data, &itemInput); err != nil {
+			return 0, err
+		}
+		if itemInput.Key != nil {
+			if _, ok := stream.itemKeyToModel[*itemInput.Key]; ok {
+				return 0, errors.New("duplicate item Key: " + *itemInput.Key)
+			}
+		}
+		itemModel, err := mapItemModel(itemInput, stream.tierKeyToModel, stream.itemKeyToModel)
+		if err != nil {
+			return 0, err
+		}
+		stream.items = append(stream.items, itemModel)
+		return len(stream.items), nil
+	case BulkRecordPricing:
+		var pricingInput PricingInput
+		if err := json.Unmarshal(record.Data, &pricingInput); err != nil {
+			return 0, err
+		}
+		pricingModel := mapPricingModel(pricingInput, stream.gameTitleID, stream.pricingKeyToModel)
+		stream.pricings = append(stream.pricings, pricingModel)
+		return len(stream.pricings), nil
+	case BulkRecordPolicies:
+		var policiesInput PoliciesInput
+		if err := json.Unmarshal(record.Data, &policiesInput); err != nil {
+			return 0, err
+		}
+		policyModel, err := mapPolicyModel(policiesInput, stream.gameTitleID, stream.itemKeyToModel, stream.policiesKeyToModel)
+		if err != nil {
+			return 0, err
+		}
+		stream.policies = append(stream.policies, policyModel)
+		return len(stream.policies), nil
+	case BulkRecordPlan:
+		var planInput PlanInput
+		if err := json.Unmarshal(record.Data, &planInput); err != nil {
+			return 0, err
+		}
+		planModel, err := mapPlanModel(planInput, stream.gameTitleID, stream.tierKeyToModel, stream.itemKeyToModel, stream.planKeyToModel)
+		if err != nil {
+			return 0, err
+		}
+		stream.plans = append(stream.plans, planModel)
+		return len(stream.plans), nil
+	case BulkRecordPreset:
+		var presetInput PresetInput
+		if err := json.Unmarshal(record.Data, &presetInput); err != nil {
+			return 0, err
+		}
+		presetModel, err := mapPresetModel(presetInput, stream.gameTitleID, stream.pricingKeyToModel, stream.policiesKeyToModel, stream.planKeyToModel)
+		if err != nil {
+			return 0, err
+		}
+		stream.presets = append(stream.presets, presetModel)
+		return len(stream.presets), nil
+	case BulkRecordBanner:
+		var bannerInput BannerInput
+		if err := json.Unmarshal(record.Data, &bannerInput); err != nil {
+			return 0, err
+		}
+		bannerModel, err := mapBannerModel(bannerInput, stream.gameTitleID, stream.tierKeyToModel, stream.itemKeyToModel)
+		if err != nil {
+			return 0, err
+		}
+		stream.banners = append(stream.banners, bannerModel)
+		return len(stream.banners), nil
+	default:
+		return 0, errors.New("invalid record type: " + record.Type)
+	}
+}
+
+func (stream *gameTitleBulkStream) flush() error {
+	pendingType := stream.pendingType
+	stream.pendingType = ""
+	var err error
+	switch pendingType {
+	case BulkRecordTier:
+		if err = stream.tx.Create(stream.tiers).Error; err == nil {
+			stream.progress.Tiers += len(stream.tiers)
+		}
+	case BulkRecordItem:
+		if err = stream.tx.Create(stream.items).Error; err == nil {
+			stream.progress.Items += len(stream.items)
+			created := make(map[*model.Item]bool, len(stream.items))
+			for _, itemModel := range stream.items {
+				created[itemModel] = true
+			}
+			for key, itemModel := range stream.itemKeyToModel {
+				if created[itemModel] {
+					stream.itemKeyToModel[key] = &model.Item{ID: itemModel.ID, TierID: itemModel.TierID}
+				}
+			}
+		}
+	case BulkRecordPricing:
+		if err = stream.tx.Create(stream.pricings).Error; err == nil {
+			stream.progress.Pricings += len(stream.pricings)
+		}
+	case BulkRecordPolicies:
+		if err = stream.tx.Create(stream.policies).Error; err == nil {
+			stream.progress.Policies += len(stream.policies)
+		}
+	case BulkRecordPlan:
+		if err = stream.tx.Create(stream.plans).Error; err == nil {
+			stream.progress.Plans += len(stream.plans)
+		}
+	case BulkRecordPreset:
+		if err = stream.tx.Create(stream.presets).Error; err == nil {
+			stream.progress.Presets += len(stream.presets)
+		}
+	case BulkRecordBanner:
+		if err = stream.tx.Create(stream.banners).Error; err == nil {
+			stream.progress.Banners += len(stream.banners)
+		}
+	default:
+		return nil
+	}
+	stream.clear()
+	if err != nil {
+		return fmt.Errorf("lines %d-%d: %w", stream.pendingFirstLine, stream.pendingLastLine, err)
+	}
+	progress := stream.progress
+	stream.write(GameTitleBulkStreamLine{Progress: &progress})
+	return nil
+}
+
+func (stream *gameTitleBulkStream) fail(err error) {
+	if !stream.active {
+		return
+	}
+	stream.active = false
+	if stream.tx != nil {
+		stream.tx.Rollback()
+		stream.tx = nil
+	}
+	stream.clear()
+	addAuditTarget(stream.ctx, stream.progress.Slug, err)
+	stream.response.Failure++
+	stream.response.Errors = append(stream.response.Errors, IndexErrorTuple{
+		Index: stream.index,
+		Error: err.Error(),
+	})
+}
+
+func (stream *gameTitleBulkStream) finish() {
+	if !stream.active {
+		return
+	}
+	if err := stream.flush(); err != nil {
+		stream.fail(err)
+		return
+	}
+	if err := stream.tx.Commit().Error; err != nil {
+		stream.tx = nil
+		stream.fail(err)
+		return
+	}
+	stream.tx = nil
+	stream.active = false
+	addAuditTarget(stream.ctx, stream.progress.Slug, nil)
+	stream.response.Success++
+	stream.progress.Done = true
+	progress := stream.progress
+	stream.write(GameTitleBulkStreamLine{Progress: &progress})
+}
+
+func (stream *gameTitleBulkStream) clear() {
+	stream.pendingType = ""
+	stream.tiers = nil
+	stream.items = nil
+	stream.pricings = nil
+	stream.policies = nil
+	stream.plans = nil
+	stream.presets = nil
+	stream.banners = nil
+}
+
+func (stream *gameTitleBulkStream) write(line GameTitleBulkStreamLine) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	stream.ctx.Writer.Write(append(data, '\n'))
+	stream.ctx.Writer.Flush()
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"gacha-simulator/model"
+	"hash"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const AuditLogCountPerPage = 50
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+type AuditLog struct {
+	ID            uint      `json:"id"`
+	ClientID      string    `json:"clientId"`
+	Method        string    `json:"method"`
+	Route         string    `json:"route"`
+	GameTitleSlug string    `json:"gameTitleSlug"`
+	PayloadHash   string    `json:"payloadHash"`
+	Status        int       `json:"status"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error"`
+	Time          time.Time `json:"time"`
+}
+
+type auditTarget struct {
+	slug string
+	err  error
+}
+
+type hashingReadCloser struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func (reader *hashingReadCloser) Read(p []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(p)
+	reader.hash.Write(p[:n])
+	return n, err
+}
+
+func AuditAdminMutations(ctx *gin.Context) {
+	if ctx.Request.Method == http.MethodGet ||
+		ctx.Request.Method == http.MethodHead ||
+		ctx.Request.Method == http.MethodOptions {
+		ctx.Next()
+		return
+	}
+	body := &hashingReadCloser{
+		ReadCloser: ctx.Request.Body,
+		hash:       sha256.New(),
+	}
+	ctx.Request.Body = body
+	ctx.Next()
+	io.Copy(io.Discard, body)
+	auditLogsModel := mapAuditLogsModel(ctx, hex.EncodeToString(body.hash.Sum(nil)))
+	if err := model.DB.Create(auditLogsModel).Error; err != nil {
+		ctx.Error(err)
+	}
+}
+
+func GetAuditLogs(c *gin.Context) {
+	pageIndex, err := getPageIndex(c)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	from, err := getTimeQuery(c, "from")
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	to, err := getTimeQuery(c, "to")
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	scope := auditLogsFiltered(c.Query("slug"), c.Query("actor"), from, to)
+	count := AuditLogCountPerPage
+	var total int64
+	if err := model.DB.
+		Scopes(scope).
+		Count(&total).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	var auditLogsModel []model.AuditLog
+	if err := model.DB.
+		Scopes(scope).
+		Order("time desc, id desc").
+		Offset(pageIndex * count).
+		Limit(count).
+		Find(&auditLogsModel).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	auditLogs := mapAuditLogs(auditLogsModel)
+	pagination := mapPagination(total, pageIndex, count, len(auditLogs), auditLogs)
+	c.JSON(http.StatusOK, &pagination)
+}
+
+func addAuditTarget(c *gin.Context, slug string, err error) {
+	var targets []auditTarget
+	if value, ok := c.Get("audit_targets"); ok {
+		targets = value.([]auditTarget)
+	}
+	c.Set("audit_targets", append(targets, auditTarget{slug: slug, err: err}))
+}
+
+func auditLogsFiltered(slug, actor string, from, to *time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Model(&model.AuditLog{})
+		if slug != "" {
+			db = db.Where("game_title_slug = ?", slug)
+		}
+		if actor != "" {
+			db = db.Where("client_id = ?", actor)
+		}
+		if from != nil {
+			db = db.Where("time >= ?", *from)
+		}
+		if to != nil {
+			db = db.Where("time < ?", *to)
+		}
+		return db
+	}
+}
+
+func mapAuditLogsModel(c *gin.Context, payloadHash string) []*model.AuditLog {
+	var targets []auditTarget
+	if value, ok := c.Get("audit_targets"); ok {
+		targets = value.([]auditTarget)
+	}
+	if len(targets) == 0 {
+		targets = append(targets, auditTarget{slug: c.Param("gameTitleSlug")})
+	}
+	clientID, _ := getClientID(c)
+	status := c.Writer.Status()
+	now := time.Now()
+	auditLogsModel := make([]*model.AuditLog, 0)
+	for _, target := range targets {
+		auditLogModel := model.AuditLog{
+			ClientID:      clientID,
+			Method:        c.Request.Method,
+			Route:         c.FullPath(),
+			GameTitleSlug: target.slug,
+			PayloadHash:   payloadHash,
+			Status:        status,
+			Outcome:       AuditOutcomeSuccess,
+			Time:          now,
+		}
+		if status >= http.StatusBadRequest || target.err != nil {
+			auditLogModel.Outcome = AuditOutcomeFailure
+		}
+		if target.err != nil {
+			auditLogModel.Error = target.err.Error()
+		}
+		auditLogsModel = append(auditLogsModel, &auditLogModel)
+	}
+	return auditLogsModel
+}
+
+func mapAuditLogs(auditLogsModel []model.AuditLog) []AuditLog {
+	auditLogs := make([]AuditLog, 0)
+	for _, auditLogModel := range auditLogsModel {
+		auditLogs = append(auditLogs, AuditLog{
+			ID:            auditLogModel.ID,
+			ClientID:      auditLogModel.ClientID,
+			Method:        auditLogModel.Method,
+			Route:         auditLogModel.Route,
+			GameTitleSlug: auditLogModel.GameTitleSlug,
+			PayloadHash:   auditLogModel.PayloadHash,
+			Status:        auditLogModel.Status,
+			Outcome:       auditLogModel.Outcome,
+			Error:         auditLogModel.Error,
+			Time:          auditLogModel.Time,
+		})
+	}
+	return auditLogs
+}
+
+func getTimeQuery(c *gin.Context, key string) (*time.Time, error) {
+	timeStr := c.Query(key)
+	if len(timeStr) == 0 {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"errors"
+	"gacha-simulator/gacha"
+	"gacha-simulator/model"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	lru "github.com/hashicorp/golang-lru"
+	"gorm.io/gorm"
+)
+
+const BannerPoolCacheSize = 100
+
+var bannerPoolCache *lru.Cache
+
+func init() {
+	var err error
+	bannerPoolCache, err = lru.New(BannerPoolCacheSize)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func GetBanners(c *gin.Context) {
+	gameTitleSlug := c.Param("gameTitleSlug")
+	active := c.DefaultQuery("active", ActiveAll)
+	if !isValidActive(active) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	bannersModel, err := getBannersModel(gameTitleSlug, active, time.Now())
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	banners := mapBanners(bannersModel, c)
+	c.JSON(http.StatusOK, &banners)
+}
+
+func GetBanner(c *gin.Context) {
+	gameTitleSlug := c.Param("gameTitleSlug")
+	bannerID, err := strconv.ParseUint(c.Param("bannerID"), 10, 64)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	bannerModel, err := getBannerModel(uint(bannerID))
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if bannerModel == nil || bannerModel.GameTitle == nil || bannerModel.GameTitle.Slug != gameTitleSlug {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	poolTiersModel, err := getBannerPoolModel(*bannerModel, true)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	banner := mapBanner(*bannerModel, c)
+	banner.Tiers = mapPoolTiers(poolTiersModel, c)
+	c.JSON(http.StatusOK, banner)
+}
+
+func getBannersModel(gameTitleSlug, active string, now time.Time) ([]model.Banner, error) {
+	var bannersModel []model.Banner
+	if err := model.DB.
+		Joins("JOIN game_titles on game_titles.id=banners.game_title_id AND game_titles.deleted_at IS NULL").
+		Where("game_titles.slug = ?", gameTitleSlug).
+		Scopes(scheduledAt("banners", active, now)).
+		Preload("Items.Item.Tier.Translations").
+		Preload("Items.Item.Translations").
+		Preload("Translations").
+		Find(&bannersModel).
+		Error; err != nil {
+		return nil, err
+	}
+	return bannersModel, nil
+}
+
+func getBannerModel(bannerID uint) (*model.Banner, error) {
+	var bannerModel model.Banner
+	if err := model.DB.
+		Preload("GameTitle").
+		Preload("Tiers").
+		Preload("Items.Item.Tier.Translations").
+		Preload("Items.Item.Translations").
+		Preload("Translations").
+		First(&bannerModel, bannerID).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &bannerModel, nil
+}
+
+func getBannerPoolModel(bannerModel model.Banner, translated bool) ([]model.Tier, error) {
+	var tiersModel []model.Tier
+	db := model.DB.
+		Where("game_title_id = ?", bannerModel.GameTitleID).
+		Order("id")
+	if translated {
+		db = db.
+			Preload("Items.Translations").
+			Preload("Translations")
+	} else {
+		db = db.Preload("Items")
+	}
+	if err := db.Find(&tiersModel).Error; err != nil {
+		return nil, err
+	}
+	return applyBanner(bannerModel, tiersModel), nil
+}
+
+func getBannerGachaTiers(bannerID, gameTitleID uint) ([]gacha.Tier, error) {
+	bannerModel, err := getBannerModel(bannerID)
+	if err != nil {
+		return nil, err
+	}
+	if bannerModel == nil || bannerModel.GameTitleID != gameTitleID {
+		return nil, nil
+	}
+	if !isInTimeWindow(bannerModel.StartTime, bannerModel.EndTime, time.Now()) {
+		return nil, nil
+	}
+	if tiers, ok := bannerPoolCache.Get(bannerModel.ID); ok {
+		return tiers.([]gacha.Tier), nil
+	}
+	poolTiersModel, err := getBannerPoolModel(*bannerModel, false)
+	if err != nil {
+		return nil, err
+	}
+	tiers := mapGachaTiers(poolTiersModel)
+	for i := range tiers {
+		for j := range tiers[i].Items {
+			tiers[i].Items[j].Tier = &tiers[i]
+		}
+	}
+	bannerPoolCache.Add(bannerModel.ID, tiers)
+	return tiers, nil
+}
+
+func isInTimeWindow(startTime, endTime *time.Time, now time.Time) bool {
+	if startTime != nil && startTime.After(now) {
+		return false
+	}
+	if endTime != nil && !endTime.After(now) {
+		return false
+	}
+	return true
+}
+
+func applyBanner(bannerModel model.Banner, tiersModel []model.Tier) []model.Tier {
+	tierOverrides := make(map[uint]model.BannerTier)
+	for _, bannerTier := range bannerModel.Tiers {
+		tierOverrides[bannerTier.TierID] = bannerTier
+	}
+	itemOverrides := make(map[uint]model.BannerItem)
+	for _, bannerItem := range bannerModel.Items {
+		itemOverrides[bannerItem.ItemID] = bannerItem
+	}
+	poolTiersModel := make([]model.Tier, 0)
+	for _, tierModel := range tiersModel {
+		if tierOverride, ok := tierOverrides[tierModel.ID]; ok && tierOverride.Ratio != nil {
+			tierModel.Ratio = *tierOverride.Ratio
+		}
+		if tierModel.Ratio <= 0 {
+			continue
+		}
+		itemsModel := make([]model.Item, 0)
+		for _, itemModel := range tierModel.Items {
+			itemOverride, ok := itemOverrides[itemModel.ID]
+			if (bannerModel.RestrictItems && !ok) || itemOverride.Excluded {
+				continue
+			}
+			if itemOverride.Ratio != nil {
+				itemModel.Ratio = *itemOverride.Ratio
+			}
+			if itemOverride.Featured && itemOverride.Boost > 1 {
+				itemModel.Ratio *= itemOverride.Boost
+			}
+			if itemModel.Ratio <= 0 {
+				continue
+			}
+			itemsModel = append(itemsModel, itemModel)
+		}
+		if len(itemsModel) == 0 {
+			continue
+		}
+		tierModel.Items = itemsModel
+		poolTiersModel = append(poolTiersModel, tierModel)
+	}
+	return poolTiersModel
+}
+
+func mapGachaTiers(tiersModel []model.Tier) []gacha.Tier {
+	tiers := make([]gacha.Tier, 0)
+	for _, tierModel := range tiersModel {
+		items := make([]gacha.Item, 0)
+		for _, itemModel := range tierModel.Items {
+			items = append(items, gacha.Item{
+				ID:        itemModel.ID,
+				Ratio:     itemModel.Ratio,
+				ShopPrice: itemModel.ShopPrice,
+			})
+		}
+		tiers = append(tiers, gacha.Tier{
+			ID:              tierModel.ID,
+			Ratio:           tierModel.Ratio,
+			ConversionValue: tierModel.ConversionValue,
+			Items:           items,
+		})
+	}
+	return tiers
+}
+
+func mapPoolTiers(tiersModel []model.Tier, c *gin.Context) []Tier {
+	tiers := make([]Tier, 0)
+	for i := 0; i < len(tiersModel); i++ {
+		tier := mapTier(tiersModel[i], c)
+		tier.Items = make([]Item, 0)
+		for j := 0; j < len(tiersModel[i].Items); j++ {
+			itemModel := tiersModel[i].Items[j]
+			itemModel.Tier = &tiersModel[i]
+			item := mapItem(itemModel, c)
+			item.Tier = nil
+			tier.Items = append(tier.Items, *item)
+		}
+		tiers = append(tiers, *tier)
+	}
+	return tiers
+}
+
+func mapBanner(bannerModel model.Banner, c *gin.Context) *Banner {
+	preferred := getPreferredLanguage(c)
+	i := getTranslationIndex(preferred, bannerModel)
+	featuredItems := make([]Item, 0)
+	for _, bannerItem := range bannerModel.Items {
+		if bannerItem.Featured && !bannerItem.Excluded && bannerItem.Item != nil {
+			featuredItems = append(featuredItems, *mapItem(*bannerItem.Item, c))
+		}
+	}
+	return &Banner{
+		ID:            bannerModel.ID,
+		RestrictItems: bannerModel.RestrictItems,
+		StartTime:     bannerModel.StartTime,
+		EndTime:       bannerModel.EndTime,
+		Name:          bannerModel.Translations[i].Name,
+		Description:   bannerModel.Translations[i].Description,
+		FeaturedItems: featuredItems,
+	}
+}
+
+func mapBanners(bannersModel []model.Banner, c *gin.Context) []Banner {
+	banners := make([]Banner, 0)
+	for i := 0; i < len(bannersModel); i++ {
+		banner := mapBanner(bannersModel[i], c)
+		banners = append(banners, *banner)
+	}
+	return banners
+}
@@ -21,12 +21,13 @@ type GameTitle struct {
 }
 
 type Tier struct {
-	ID        uint   `json:"id"`
-	Ratio     int    `json:"ratio"`
-	ImageURL  string `json:"imageUrl"`
-	Name      string `json:"name"`
-	ShortName string `json:"shortName"`
-	Items     []Item `json:"items,omitempty"`
+	ID              uint    `json:"id"`
+	Ratio           int     `json:"ratio"`
+	ConversionValue float64 `json:"conversionValue"`
+	ImageURL        string  `json:"imageUrl"`
+	Name            string  `json:"name"`
+	ShortName       string  `json:"shortName"`
+	Items           []Item  `json:"items,omitempty"`
 }
 
 type TierWithNumber struct {
@@ -35,13 +36,14 @@ type TierWithNumber struct {
 }
 
 type Item struct {
-	ID           uint   `json:"id"`
-	Ratio        int    `json:"ratio"`
-	ImageURL     string `json:"imageUrl"`
-	Tier         *Tier  `json:"tier"`
-	Name         string `json:"name"`
-	ShortName    string `json:"shortName"`
-	ShortNameAlt string `json:"shortNameAlt"`
+	ID           uint     `json:"id"`
+	Ratio        int      `json:"ratio"`
+	ShopPrice    *float64 `json:"shopPrice"`
+	ImageURL     string   `json:"imageUrl"`
+	Tier         *Tier    `json:"tier"`
+	Name         string   `json:"name"`
+	ShortName    string   `json:"shortName"`
+	ShortNameAlt string   `json:"shortNameAlt"`
 }
 
 type ItemWithNumber struct {
@@ -67,35 +69,60 @@ type Policies struct {
 }
 
 type Plan struct {
-	ID                   uint             `json:"id"`
-	Budget               float64          `json:"budget"`
-	MaxConsecutiveGachas int              `json:"maxConsecutiveGachas"`
-	ItemGoals            bool             `json:"itemGoals"`
-	WantedItems          []ItemWithNumber `json:"wantedItems"`
-	TierGoals            bool             `json:"tierGoals"`
-	WantedTiers          []TierWithNumber `json:"wantedTiers"`
-	Name                 string           `json:"name"`
+	ID                   uint                       `json:"id"`
+	Budget               float64                    `json:"budget"`
+	MaxConsecutiveGachas int                        `json:"maxConsecutiveGachas"`
+	ItemGoals            bool                       `json:"itemGoals"`
+	WantedItems          []ItemWithNumber           `json:"wantedItems"`
+	TierGoals            bool                       `json:"tierGoals"`
+	WantedTiers          []TierWithNumber           `json:"wantedTiers"`
+	Goal                 *gacha.Goal                `json:"goal"`
+	StopStrategies       []gacha.StopStrategyConfig `json:"stopStrategies"`
+	UseShop              bool                       `json:"useShop"`
+	Name                 string                     `json:"name"`
 }
 
 type Preset struct {
-	ID          uint      `json:"id"`
-	Pricing     *Pricing  `json:"pricing"`
-	Policies    *Policies `json:"policies"`
-	Plan        *Plan     `json:"plan"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
+	ID          uint       `json:"id"`
+	Pricing     *Pricing   `json:"pricing"`
+	Policies    *Policies  `json:"policies"`
+	Plan        *Plan      `json:"plan"`
+	StartTime   *time.Time `json:"startTime"`
+	EndTime     *time.Time `json:"endTime"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+}
+
+type Banner struct {
+	ID            uint       `json:"id"`
+	RestrictItems bool       `json:"restrictItems"`
+	StartTime     *time.Time `json:"startTime"`
+	EndTime       *time.Time `json:"endTime"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	FeaturedItems []Item     `json:"featuredItems"`
+	Tiers         []Tier     `json:"tiers,omitempty"`
 }
 
 type Result struct {
-	ID            uint          `json:"id"`
-	UserID        string        `json:"userID"`
-	Public        bool          `json:"public"`
-	Request       gacha.Request `json:"request,omitempty"`
-	ItemIDs       []uint        `json:"itemIDs"`
-	GoalsAchieved bool          `json:"goalsAchieved"`
-	MoneySpent    float64       `json:"moneySpent"`
-	Time          time.Time     `json:"time"`
-	GameTitle     *GameTitle    `json:"gameTitle,omitempty"`
+	ID                   uint          `json:"id"`
+	UserID               string        `json:"userID"`
+	Public               bool          `json:"public"`
+	Pinned               bool          `json:"pinned"`
+	Request              gacha.Request `json:"request,omitempty"`
+	ItemIDs              []uint        `json:"itemIDs"`
+	GoalsAchieved        bool          `json:"goalsAchieved"`
+	GoalsAchievedViaShop bool          `json:"goalsAchievedViaShop"`
+	MoneySpent           float64       `json:"moneySpent"`
+	Duplicates           int           `json:"duplicates"`
+	ConversionCurrency   float64       `json:"conversionCurrency"`
+	StopReason           string        `json:"stopReason"`
+	LuckPercentile       *float64      `json:"luckPercentile"`
+	Time                 time.Time     `json:"time"`
+	GameTitle            *GameTitle    `json:"gameTitle,omitempty"`
+	BannerID             *uint         `json:"bannerId,omitempty"`
+	PresetID             *uint         `json:"presetId,omitempty"`
+	PresetUnmodified     bool          `json:"presetUnmodified"`
 }
 
 func getTranslationIndex(preferred []language.Tag, translationHolder model.TranslationHolder) int {
@@ -126,3 +153,12 @@ func getUserID(c *gin.Context) (string, bool) {
 	userID := accessToken.(oauth2.TokenInfo).GetUserID()
 	return userID, true
 }
+
+func getClientID(c *gin.Context) (string, bool) {
+	accessToken, ok := c.Get("access_token")
+	if !ok {
+		return "", false
+	}
+	clientID := accessToken.(oauth2.TokenInfo).GetClientID()
+	return clientID, true
+}
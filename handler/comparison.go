@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"gacha-simulator/gacha"
+	"gacha-simulator/model"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DefaultComparisonRuns = 1000
+
+type PlanComparisonRequest struct {
+	GameTitle GameTitle `json:"gameTitle"`
+	BannerID  *uint     `json:"bannerId"`
+	PresetID  *uint     `json:"presetId"`
+	Plan      *Plan     `json:"plan"`
+	Runs      int       `json:"runs"`
+}
+
+type PlanComparison struct {
+	Pricing          *Pricing  `json:"pricing"`
+	Policies         *Policies `json:"policies"`
+	SuccessRate      float64   `json:"successRate"`
+	MeanMoneySpent   float64   `json:"meanMoneySpent"`
+	MedianMoneySpent float64   `json:"medianMoneySpent"`
+	MeanPulls        float64   `json:"meanPulls"`
+	CostPerSuccess   *float64  `json:"costPerSuccess"`
+	Error            string    `json:"error,omitempty"`
+}
+
+func PostPlanComparison(c *gin.Context) {
+	var comparisonRequest PlanComparisonRequest
+	c.Bind(&comparisonRequest)
+	gameTitleModel, err := getGameTitleModelByID(comparisonRequest.GameTitle.ID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if gameTitleModel == nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	plan, ok, err := resolveComparisonPlan(comparisonRequest, gameTitleModel.ID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var tiers []gacha.Tier
+	if comparisonRequest.BannerID != nil {
+		tiers, err = getBannerGachaTiers(*comparisonRequest.BannerID, gameTitleModel.ID)
+	} else {
+		tiers, err = getGachaTiers(gameTitleModel.ID)
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if tiers == nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	pricingsModel, err := getPricingsModelByGameTitleID(gameTitleModel.ID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	policiesModel, err := getPoliciesModelByGameTitleID(gameTitleModel.ID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if len(pricingsModel) == 0 {
+		c.Status(http.StatusUnprocessableEntity)
+		return
+	}
+	policiesOptions := make([]*model.Policies, 0)
+	for i := range policiesModel {
+		policiesOptions = append(policiesOptions, &policiesModel[i])
+	}
+	if len(policiesOptions) == 0 {
+		policiesOptions = append(policiesOptions, nil)
+	}
+
+	runs := comparisonRequest.Runs
+	if runs == 0 {
+		runs = DefaultComparisonRuns
+	}
+	if runs < 0 || runs*len(pricingsModel)*len(policiesOptions) > getMaxSimulationRuns() {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	ctx := c.Request.Context()
+	comparisons := make([]PlanComparison, 0)
+	for _, pricingModel := range pricingsModel {
+		for _, policiesOption := range policiesOptions {
+			request := gacha.Request{
+				Tiers:         tiers,
+				ItemsIncluded: comparisonRequest.BannerID != nil,
+				Pricing:       mapGachaPricingFromModel(pricingModel),
+				Policies:      mapGachaPolicies(Policies{}),
+				Plan:          plan,
+			}
+			var policies *Policies
+			if policiesOption != nil {
+				request.Policies = mapGachaPoliciesFromModel(*policiesOption)
+				policies = mapPolicy(*policiesOption, c)
+			}
+			BindGachaRequestLoaders(&request)
+			if err := gacha.Validate(request); err != nil {
+				comparisons = append(comparisons, PlanComparison{
+					Pricing:  mapPricing(pricingModel, c),
+					Policies: policies,
+					Error:    err.Error(),
+				})
+				continue
+			}
+			summary, err := gacha.Simulate(ctx, request, runs, nil)
+			if err != nil {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			comparisons = append(comparisons, mapPlanComparison(summary, mapPricing(pricingModel, c), policies))
+		}
+	}
+	sortPlanComparisons(comparisons)
+
+	c.JSON(http.StatusOK, comparisons)
+}
+
+func sortPlanComparisons(comparisons []PlanComparison) {
+	sort.SliceStable(comparisons, func(i, j int) bool {
+		if (comparisons[i].Error == "") != (comparisons[j].Error == "") {
+			return comparisons[i].Error == ""
+		}
+		if comparisons[i].SuccessRate != comparisons[j].SuccessRate {
+			return comparisons[i].SuccessRate > comparisons[j].SuccessRate
+		}
+		return comparisons[i].MeanMoneySpent < comparisons[j].MeanMoneySpent
+	})
+}
+
+func resolveComparisonPlan(comparisonRequest PlanComparisonRequest, gameTitleID uint) (gacha.Plan, bool, error) {
+	if comparisonRequest.Plan != nil {
+		return mapGachaPlan(*comparisonRequest.Plan), true, nil
+	}
+	if comparisonRequest.PresetID == nil {
+		return gacha.Plan{}, false, nil
+	}
+	presetModel, err := getPresetModelByID(*comparisonRequest.PresetID)
+	if err != nil {
+		return gacha.Plan{}, false, err
+	}
+	if presetModel == nil || presetModel.GameTitleID != gameTitleID || presetModel.Plan == nil {
+		return gacha.Plan{}, false, nil
+	}
+	plan, err := mapGachaPlanFromModel(*presetModel.Plan)
+	if err != nil {
+		return gacha.Plan{}, false, err
+	}
+	return plan, true, nil
+}
+
+func getPoliciesModelByGameTitleID(gameTitleID uint) ([]model.Policies, error) {
+	var policiesModel []model.Policies
+	if err := model.DB.
+		Where("game_title_id = ?", gameTitleID).
+		Preload("PityItem.Tier.Translations").
+		Preload("PityItem.Translations").
+		Preload("Translations").
+		Order("id").
+		Find(&policiesModel).
+		Error; err != nil {
+		return nil, err
+	}
+	return policiesModel, nil
+}
+
+func mapPlanComparison(summary gacha.SimulationSummary, pricing *Pricing, policies *Policies) PlanComparison {
+	var costPerSuccess *float64
+	if summary.SuccessRate > 0 {
+		cost := summary.MeanMoneySpent / summary.SuccessRate
+		costPerSuccess = &cost
+	}
+	return PlanComparison{
+		Pricing:          pricing,
+		Policies:         policies,
+		SuccessRate:      summary.SuccessRate,
+		MeanMoneySpent:   summary.MeanMoneySpent,
+		MedianMoneySpent: summary.MedianMoneySpent,
+		MeanPulls:        summary.MeanPulls,
+		CostPerSuccess:   costPerSuccess,
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"gacha-simulator/gacha"
+	"testing"
+)
+
+func TestResolveComparisonPlan(t *testing.T) {
+	plan, ok, err := resolveComparisonPlan(PlanComparisonRequest{
+		Plan: &Plan{
+			Budget:      1000,
+			ItemGoals:   true,
+			WantedItems: []ItemWithNumber{{Item: Item{ID: 3}, Number: 2}},
+			StopStrategies: []gacha.StopStrategyConfig{
+				{Type: gacha.StopStrategyFirstTopTier},
+			},
+		},
+	}, 1)
+	if err != nil || !ok {
+		t.Error("Unexpected resolution failure")
+	}
+	if plan.Budget != 1000 || plan.WantedItems[3] != 2 || len(plan.StopStrategies) != 1 {
+		t.Errorf("Unexpected plan %+v", plan)
+	}
+	if len(plan.WantedTiers) != 0 {
+		t.Error("Unexpected wanted tiers")
+	}
+
+	_, ok, err = resolveComparisonPlan(PlanComparisonRequest{}, 1)
+	if err != nil || ok {
+		t.Error("Unexpected resolution without plan or preset")
+	}
+}
+
+func TestSortPlanComparisons(t *testing.T) {
+	comparisons := []PlanComparison{
+		{SuccessRate: 0.5, MeanMoneySpent: 100},
+		{Error: "pity item not found in tiers"},
+		{SuccessRate: 0.9, MeanMoneySpent: 300},
+		{SuccessRate: 0.9, MeanMoneySpent: 200},
+		{SuccessRate: 0, MeanMoneySpent: 50},
+	}
+	sortPlanComparisons(comparisons)
+	expected := []PlanComparison{
+		{SuccessRate: 0.9, MeanMoneySpent: 200},
+		{SuccessRate: 0.9, MeanMoneySpent: 300},
+		{SuccessRate: 0.5, MeanMoneySpent: 100},
+		{SuccessRate: 0, MeanMoneySpent: 50},
+		{Error: "pity item not found in tiers"},
+	}
+	for i := range expected {
+		if comparisons[i].SuccessRate != expected[i].SuccessRate ||
+			comparisons[i].MeanMoneySpent != expected[i].MeanMoneySpent ||
+			comparisons[i].Error != expected[i].Error {
+			t.Errorf("Unexpected comparison at %d: %+v", i, comparisons[i])
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gacha-simulator/gacha"
+	"gacha-simulator/model"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const ExportBatchSize = 100
+
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+)
+
+const (
+	ExportRowsSession = "session"
+	ExportRowsPull    = "pull"
+)
+
+type ExportSession struct {
+	Result
+	Items []Item `json:"items"`
+}
+
+type ExportPull struct {
+	ResultID        uint      `json:"resultId"`
+	Time            time.Time `json:"time"`
+	Index           int       `json:"index"`
+	Item            Item      `json:"item"`
+	Pity            bool      `json:"pity"`
+	Discounted      bool      `json:"discounted"`
+	CumulativeSpend float64   `json:"cumulativeSpend"`
+}
+
+type gachaExport struct {
+	ctx       *gin.Context
+	format    string
+	rows      string
+	csvWriter *csv.Writer
+	count     int
+	items     map[uint]Item
+}
+
+func GetGachasExport(c *gin.Context) {
+	gameTitleSlug := c.Param("gameTitleSlug")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	rows := c.DefaultQuery("rows", ExportRowsSession)
+	if (format != ExportFormatCSV && format != ExportFormatJSON) ||
+		(rows != ExportRowsSession && rows != ExportRowsPull) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	export := gachaExport{
+		ctx:    c,
+		format: format,
+		rows:   rows,
+		items:  make(map[uint]Item),
+	}
+	if format == ExportFormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-gachas-%s.%s\"", gameTitleSlug, rows, format))
+	c.Status(http.StatusOK)
+	if err := export.begin(); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var resultsModel []model.Result
+	if err := model.DB.
+		Scopes(gameTitleGachasByUser(&resultsModel, gameTitleSlug, userID)).
+		FindInBatches(&resultsModel, ExportBatchSize, func(tx *gorm.DB, batch int) error {
+			return export.write(resultsModel)
+		}).
+		Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := export.end(); err != nil {
+		c.Error(err)
+	}
+}
+
+func (export *gachaExport) begin() error {
+	if export.format == ExportFormatJSON {
+		_, err := io.WriteString(export.ctx.Writer, "[")
+		return err
+	}
+	export.csvWriter = csv.NewWriter(export.ctx.Writer)
+	if export.rows == ExportRowsPull {
+		return export.csvWriter.Write([]string{
+			"resultId", "time", "index", "itemId", "itemName", "tierId", "tierName",
+			"pity", "discounted", "cumulativeSpend",
+		})
+	}
+	return export.csvWriter.Write([]string{
+		"resultId", "time", "goalsAchieved", "moneySpent", "pulls", "luckPercentile",
+		"presetId", "bannerId", "public", "items",
+	})
+}
+
+func (export *gachaExport) write(resultsModel []model.Result) error {
+	if err := export.loadItems(resultsModel); err != nil {
+		return err
+	}
+	for i := 0; i < len(resultsModel); i++ {
+		pulls, err := export.getPulls(resultsModel[i])
+		if err != nil {
+			return err
+		}
+		if export.rows == ExportRowsPull {
+			for _, pull := range pulls {
+				if err := export.writePull(resultsModel[i], pull); err != nil {
+					return err
+				}
+			}
+		} else {
+			if err := export.writeSession(resultsModel[i], pulls); err != nil {
+				return err
+			}
+		}
+	}
+	if export.csvWriter != nil {
+		export.csvWriter.Flush()
+		if err := export.csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	export.ctx.Writer.Flush()
+	return nil
+}
+
+func (export *gachaExport) end() error {
+	if export.format == ExportFormatJSON {
+		_, err := io.WriteString(export.ctx.Writer, "]")
+		return err
+	}
+	export.csvWriter.Flush()
+	return export.csvWriter.Error()
+}
+
+func (export *gachaExport) writeSession(resultModel model.Result, pulls []gacha.Pull) error {
+	items := make([]Item, 0)
+	for _, pull := range pulls {
+		items = append(items, export.items[pull.ItemID])
+	}
+	if export.format == ExportFormatJSON {
+		result, err := mapResult(resultModel, export.ctx)
+		if err != nil {
+			return err
+		}
+		return export.writeJSON(ExportSession{
+			Result: *result,
+			Items:  items,
+		})
+	}
+	itemNames := make([]string, 0)
+	for _, item := range items {
+		itemNames = append(itemNames, item.Name)
+	}
+	luckPercentile := ""
+	if resultModel.LuckPercentile != nil {
+		luckPercentile = strconv.FormatFloat(*resultModel.LuckPercentile, 'f', 2, 64)
+	}
+	return export.csvWriter.Write([]string{
+		strconv.FormatUint(uint64(resultModel.ID), 10),
+		resultModel.Time.Format(time.RFC3339),
+		strconv.FormatBool(resultModel.GoalsAchieved),
+		strconv.FormatFloat(resultModel.MoneySpent, 'f', -1, 64),
+		strconv.Itoa(len(pulls)),
+		luckPercentile,
+		formatOptionalID(resultModel.PresetID),
+		formatOptionalID(resultModel.BannerID),
+		strconv.FormatBool(resultModel.Public),
+		strings.Join(itemNames, ";"),
+	})
+}
+
+func (export *gachaExport) writePull(resultModel model.Result, pull gacha.Pull) error {
+	item := export.items[pull.ItemID]
+	if export.format == ExportFormatJSON {
+		return export.writeJSON(ExportPull{
+			ResultID:        resultModel.ID,
+			Time:            resultModel.Time,
+			Index:           pull.Index,
+			Item:            item,
+			Pity:            pull.Pity,
+			Discounted:      pull.Discounted,
+			CumulativeSpend: pull.CumulativeSpend,
+		})
+	}
+	var tierID uint
+	tierName := ""
+	if item.Tier != nil {
+		tierID = item.Tier.ID
+		tierName = item.Tier.Name
+	}
+	return export.csvWriter.Write([]string{
+		strconv.FormatUint(uint64(resultModel.ID), 10),
+		resultModel.Time.Format(time.RFC3339),
+		strconv.Itoa(pull.Index),
+		strconv.FormatUint(uint64(pull.ItemID), 10),
+		item.Name,
+		strconv.FormatUint(uint64(tierID), 10),
+		tierName,
+		strconv.FormatBool(pull.Pity),
+		strconv.FormatBool(pull.Discounted),
+		strconv.FormatFloat(pull.CumulativeSpend, 'f', -1, 64),
+	})
+}
+
+func (export *gachaExport) writeJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if export.count > 0 {
+		if _, err := io.WriteString(export.ctx.Writer, ","); err != nil {
+			return err
+		}
+	}
+	export.count++
+	_, err = export.ctx.Writer.Write(b)
+	return err
+}
+
+func (export *gachaExport) loadItems(resultsModel []model.Result) error {
+	itemIDs := make([]uint, 0)
+	for i := 0; i < len(resultsModel); i++ {
+		resultItemIDs, err := getResultItemIDs(&resultsModel[i])
+		if err != nil {
+			return err
+		}
+		for _, itemID := range makeUniqueItemIDs(resultItemIDs) {
+			if _, ok := export.items[itemID]; !ok {
+				itemIDs = append(itemIDs, itemID)
+			}
+		}
+	}
+	if len(itemIDs) == 0 {
+		return nil
+	}
+	itemsModel, err := getItemsModelByIDs(makeUniqueItemIDs(itemIDs))
+	if err != nil {
+		return err
+	}
+	for _, item := range mapItems(itemsModel, export.ctx) {
+		export.items[item.ID] = item
+	}
+	return nil
+}
+
+func (export *gachaExport) getPulls(resultModel model.Result) ([]gacha.Pull, error) {
+	pulls := make([]gacha.Pull, 0)
+	if len(resultModel.Pulls) > 0 {
+		if err := json.Unmarshal(resultModel.Pulls, &pulls); err != nil {
+			return nil, err
+		}
+		if len(pulls) > 0 {
+			return pulls, nil
+		}
+	}
+	itemIDs, err := getResultItemIDs(&resultModel)
+	if err != nil {
+		return nil, err
+	}
+	pulls = make([]gacha.Pull, 0)
+	for i, itemID := range itemIDs {
+		var tierID uint
+		if item, ok := export.items[itemID]; ok && item.Tier != nil {
+			tierID = item.Tier.ID
+		}
+		pulls = append(pulls, gacha.Pull{
+			Index:  i + 1,
+			ItemID: itemID,
+			TierID: tierID,
+		})
+	}
+	return pulls, nil
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
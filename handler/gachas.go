@@ -6,6 +6,8 @@ import (
 	"gacha-simulator/gacha"
 	"gacha-simulator/model"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,20 +15,52 @@ import (
 	"gorm.io/gorm"
 )
 
+const DefaultMaxPinnedResults = 20
+const PinnedResultsLockPrefix = "gacha-simulator:pinned-results:"
+
+var maxPinnedResults int
+
 type GachaRequest struct {
-	GameTitle     GameTitle `json:"gameTitle"`
-	Tiers         []Tier    `json:"tiers"`
-	ItemsIncluded bool      `json:"itemsIncluded"`
-	Pricing       Pricing   `json:"pricing"`
-	Policies      Policies  `json:"policies"`
-	Plan          Plan      `json:"plan"`
+	GameTitle     GameTitle              `json:"gameTitle"`
+	Tiers         []Tier                 `json:"tiers"`
+	ItemsIncluded bool                   `json:"itemsIncluded"`
+	Pricing       Pricing                `json:"pricing"`
+	Policies      Policies               `json:"policies"`
+	Plan          Plan                   `json:"plan"`
+	BannerID      *uint                  `json:"bannerId"`
+	PresetID      *uint                  `json:"presetId"`
+	Overrides     *GachaRequestOverrides `json:"overrides"`
+}
+
+type GachaRequestOverrides struct {
+	Pricing  *Pricing  `json:"pricing"`
+	Policies *Policies `json:"policies"`
+	Plan     *Plan     `json:"plan"`
 }
 
 type ResultResponse struct {
 	Result
-	Items                []Item `json:"items"`
-	RemainingWantedItems []Item `json:"remainingWantedItems"`
-	RemainingWantedTiers []Tier `json:"remainingWantedTiers"`
+	Items                []Item               `json:"items"`
+	Pulls                []gacha.Pull         `json:"pulls"`
+	ShopPurchases        []gacha.ShopPurchase `json:"shopPurchases"`
+	RemainingWantedItems []Item               `json:"remainingWantedItems"`
+	RemainingWantedTiers []Tier               `json:"remainingWantedTiers"`
+	WantedItemProgress   []ItemProgress       `json:"wantedItemProgress"`
+	WantedTierProgress   []TierProgress       `json:"wantedTierProgress"`
+}
+
+type ItemProgress struct {
+	Item      Item `json:"item"`
+	Wanted    int  `json:"wanted"`
+	Obtained  int  `json:"obtained"`
+	Remaining int  `json:"remaining"`
+}
+
+type TierProgress struct {
+	Tier      Tier `json:"tier"`
+	Wanted    int  `json:"wanted"`
+	Obtained  int  `json:"obtained"`
+	Remaining int  `json:"remaining"`
 }
 
 type ResultResponseExt struct {
@@ -38,16 +72,19 @@ type ResultResponseExt struct {
 }
 
 type PatchGachaRequest struct {
-	Public bool `json:"public"`
+	Public *bool `json:"public"`
+	Pinned *bool `json:"pinned"`
 }
 
 func PostGachas(c *gin.Context) {
 	var gachaRequest GachaRequest
 	c.Bind(&gachaRequest)
-	request := mapGachaRequest(gachaRequest)
-
-	err := gacha.Validate(request)
+	request, ok, err := resolveGachaRequest(gachaRequest)
 	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
 		c.Status(http.StatusBadRequest)
 		return
 	}
@@ -58,7 +95,8 @@ func PostGachas(c *gin.Context) {
 		return
 	}
 
-	resultModel, err := mapResultModel(result, request, gachaRequest, c)
+	luckPercentile := gacha.LuckPercentileIfReady(request, result)
+	resultModel, err := mapResultModel(result, luckPercentile, request, gachaRequest, c)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
@@ -164,11 +202,47 @@ func PatchGacha(c *gin.Context) {
 		return
 	}
 
-	if err := model.DB.
+	updates := make(map[string]interface{})
+	if patchGachaRequest.Public != nil {
+		updates["public"] = *patchGachaRequest.Public
+	}
+	if patchGachaRequest.Pinned != nil {
+		updates["pinned"] = *patchGachaRequest.Pinned
+	}
+	if len(updates) == 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	tx := model.DB.Begin()
+	if patchGachaRequest.Pinned != nil && *patchGachaRequest.Pinned && !resultModel.Pinned {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", PinnedResultsLockPrefix+userID).Error; err != nil {
+			tx.Rollback()
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		pinnedCount, err := getPinnedResultCount(tx, userID)
+		if err != nil {
+			tx.Rollback()
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if pinnedCount >= int64(maxPinnedResults) {
+			tx.Rollback()
+			c.Status(http.StatusConflict)
+			return
+		}
+	}
+	if err := tx.
 		Model(&model.Result{}).
 		Where("id = ? AND user_id = ?", resultID, userID).
-		Update("public", patchGachaRequest.Public).
+		Updates(updates).
 		Error; err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
@@ -244,6 +318,55 @@ func tiersByIDs(tierIDs []uint) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+func getGameTitleModelByID(gameTitleID uint) (*model.GameTitle, error) {
+	var gameTitleModel model.GameTitle
+	if err := model.DB.
+		First(&gameTitleModel, gameTitleID).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &gameTitleModel, nil
+}
+
+func getPresetModelByID(presetID uint) (*model.Preset, error) {
+	var presetModel model.Preset
+	if err := model.DB.
+		Preload("Pricing").
+		Preload("Policies").
+		Preload("Plan").
+		First(&presetModel, presetID).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &presetModel, nil
+}
+
+func getGachaTiers(gameTitleID uint) ([]gacha.Tier, error) {
+	var tiersModel []model.Tier
+	if err := model.DB.
+		Where("game_title_id = ?", gameTitleID).
+		Order("id").
+		Find(&tiersModel).
+		Error; err != nil {
+		return nil, err
+	}
+	tiers := make([]gacha.Tier, 0)
+	for _, tierModel := range tiersModel {
+		tiers = append(tiers, gacha.Tier{
+			ID:              tierModel.ID,
+			Ratio:           tierModel.Ratio,
+			ConversionValue: tierModel.ConversionValue,
+		})
+	}
+	return tiers, nil
+}
+
 func getResultModel(resultID string) (*model.Result, error) {
 	var resultModel model.Result
 	if err := model.DB.
@@ -261,8 +384,8 @@ func getResultModel(resultID string) (*model.Result, error) {
 func getNextResultsModel(resultModel model.Result) ([]model.Result, error) {
 	var nextResultsModel []model.Result
 	if err := model.DB.
-		Where("time < ? AND game_title_id = ? AND user_id = ?", resultModel.Time, resultModel.GameTitleID, resultModel.UserID).
-		Order("time DESC").
+		Where("(time, id) < (?, ?) AND game_title_id = ? AND user_id = ?", resultModel.Time, resultModel.ID, resultModel.GameTitleID, resultModel.UserID).
+		Order("time DESC, id DESC").
 		Limit(1).
 		Find(&nextResultsModel).
 		Error; err != nil {
@@ -274,8 +397,8 @@ func getNextResultsModel(resultModel model.Result) ([]model.Result, error) {
 func getPrevResultsModel(resultModel model.Result) ([]model.Result, error) {
 	var prevResultsModel []model.Result
 	if err := model.DB.
-		Where("time > ? AND game_title_id = ? AND user_id = ?", resultModel.Time, resultModel.GameTitleID, resultModel.UserID).
-		Order("time ASC").
+		Where("(time, id) > (?, ?) AND game_title_id = ? AND user_id = ?", resultModel.Time, resultModel.ID, resultModel.GameTitleID, resultModel.UserID).
+		Order("time ASC, id ASC").
 		Limit(1).
 		Find(&prevResultsModel).
 		Error; err != nil {
@@ -284,6 +407,34 @@ func getPrevResultsModel(resultModel model.Result) ([]model.Result, error) {
 	return prevResultsModel, nil
 }
 
+func getPinnedResultCount(db *gorm.DB, userID string) (int64, error) {
+	var count int64
+	if err := db.
+		Model(&model.Result{}).
+		Where("user_id = ? AND pinned = ?", userID, true).
+		Count(&count).
+		Error; err != nil {
+		return -1, err
+	}
+	return count, nil
+}
+
+func InitLimits() {
+	maxPinnedResultsStr := os.Getenv("MAX_PINNED_RESULTS")
+	if maxPinnedResultsStr == "" {
+		maxPinnedResults = DefaultMaxPinnedResults
+		return
+	}
+	var err error
+	maxPinnedResults, err = strconv.Atoi(maxPinnedResultsStr)
+	if err != nil {
+		panic(err)
+	}
+	if maxPinnedResults < 0 {
+		panic("negative MAX_PINNED_RESULTS")
+	}
+}
+
 func getResultModelByIDAndUserID(resultID, userID string) (*model.Result, error) {
 	var resultModel model.Result
 	if err := model.DB.
@@ -305,121 +456,320 @@ func mapGachaRequest(gachaRequest GachaRequest) gacha.Request {
 		var items []gacha.Item
 		for _, item := range tier.Items {
 			items = append(items, gacha.Item{
-				ID:    item.ID,
-				Ratio: item.Ratio,
+				ID:        item.ID,
+				Ratio:     item.Ratio,
+				ShopPrice: item.ShopPrice,
 			})
 		}
 		tiers = append(tiers, gacha.Tier{
-			ID:    tier.ID,
-			Ratio: tier.Ratio,
-			Items: items,
+			ID:              tier.ID,
+			Ratio:           tier.Ratio,
+			ConversionValue: tier.ConversionValue,
+			Items:           items,
 		})
 	}
+	request := gacha.Request{
+		Tiers:         tiers,
+		ItemsIncluded: gachaRequest.ItemsIncluded,
+		Pricing:       mapGachaPricing(gachaRequest.Pricing),
+		Policies:      mapGachaPolicies(gachaRequest.Policies),
+		Plan:          mapGachaPlan(gachaRequest.Plan),
+	}
+	BindGachaRequestLoaders(&request)
+	return request
+}
+
+func BindGachaRequestLoaders(request *gacha.Request) {
+	request.GetItemCount = func(tierID uint) (int64, error) {
+		var count int64
+		if err := model.DB.
+			Model(&model.Item{}).
+			Where("tier_id", tierID).
+			Count(&count).
+			Error; err != nil {
+			return -1, err
+		}
+		return count, nil
+	}
+	request.GetItemFromIndex = func(tierID uint, index int) (*gacha.Item, error) {
+		var item model.Item
+		if err := model.DB.
+			Model(&model.Item{}).
+			Where("tier_id", tierID).
+			Offset(index).
+			Preload("Tier").
+			First(&item).
+			Error; err != nil {
+			return nil, err
+		}
+		return &gacha.Item{
+			ID:   item.ID,
+			Tier: &gacha.Tier{ID: item.Tier.ID},
+		}, nil
+	}
+	request.GetItemFromID = func(itemID uint) (*gacha.Item, error) {
+		var item model.Item
+		if err := model.DB.
+			Preload("Tier").
+			First(&item, "items.id=?", itemID).
+			Error; err != nil {
+			return nil, err
+		}
+		return &gacha.Item{
+			ID:        item.ID,
+			ShopPrice: item.ShopPrice,
+			Tier:      &gacha.Tier{ID: item.Tier.ID},
+		}, nil
+	}
+	request.GetItemCountFromIDs = func(itemIDs []uint) (int64, error) {
+		var count int64
+		if err := model.DB.
+			Model(&model.Item{}).
+			Where("id IN ?", itemIDs).
+			Count(&count).
+			Error; err != nil {
+			return -1, err
+		}
+		return count, nil
+	}
+	request.GetTierCountFromIDs = func(tierIDs []uint) (int64, error) {
+		var count int64
+		if err := model.DB.
+			Model(&model.Tier{}).
+			Where("id IN ?", tierIDs).
+			Count(&count).
+			Error; err != nil {
+			return -1, err
+		}
+		return count, nil
+	}
+}
+
+func resolveGachaRequest(gachaRequest GachaRequest) (gacha.Request, bool, error) {
+	request := mapGachaRequest(gachaRequest)
+
+	gameTitleModel, err := getGameTitleModelByID(gachaRequest.GameTitle.ID)
+	if err != nil {
+		return request, false, err
+	}
+	if gameTitleModel == nil {
+		return request, false, nil
+	}
+
+	if gachaRequest.PresetID != nil {
+		ok, err := resolvePreset(&request, gachaRequest, gameTitleModel.ID)
+		if err != nil || !ok {
+			return request, false, err
+		}
+	}
+
+	if gachaRequest.BannerID != nil {
+		tiers, err := getBannerGachaTiers(*gachaRequest.BannerID, gameTitleModel.ID)
+		if err != nil {
+			return request, false, err
+		}
+		if tiers == nil {
+			return request, false, nil
+		}
+		request.Tiers = tiers
+		request.ItemsIncluded = true
+	}
+
+	if err := gacha.Validate(request); err != nil {
+		return request, false, nil
+	}
+	return request, true, nil
+}
+
+func resolvePreset(request *gacha.Request, gachaRequest GachaRequest, gameTitleID uint) (bool, error) {
+	presetModel, err := getPresetModelByID(*gachaRequest.PresetID)
+	if err != nil {
+		return false, err
+	}
+	if presetModel == nil || presetModel.GameTitleID != gameTitleID {
+		return false, nil
+	}
+	overrides := GachaRequestOverrides{}
+	if gachaRequest.Overrides != nil {
+		overrides = *gachaRequest.Overrides
+	}
+	tiers, err := getGachaTiers(gameTitleID)
+	if err != nil {
+		return false, err
+	}
+	request.Tiers = tiers
+	request.ItemsIncluded = false
+	if overrides.Pricing != nil {
+		request.Pricing = mapGachaPricing(*overrides.Pricing)
+	} else if presetModel.Pricing != nil {
+		request.Pricing = mapGachaPricingFromModel(*presetModel.Pricing)
+	} else {
+		return false, nil
+	}
+	if overrides.Policies != nil {
+		request.Policies = mapGachaPolicies(*overrides.Policies)
+	} else if presetModel.Policies != nil {
+		request.Policies = mapGachaPoliciesFromModel(*presetModel.Policies)
+	} else {
+		request.Policies = mapGachaPolicies(Policies{})
+	}
+	if overrides.Plan != nil {
+		request.Plan = mapGachaPlan(*overrides.Plan)
+	} else if presetModel.Plan != nil {
+		plan, err := mapGachaPlanFromModel(*presetModel.Plan)
+		if err != nil {
+			return false, err
+		}
+		request.Plan = plan
+	} else {
+		return false, nil
+	}
+	return true, nil
+}
+
+func isUnmodifiedPresetRun(gachaRequest GachaRequest) bool {
+	if gachaRequest.PresetID == nil || gachaRequest.BannerID != nil {
+		return false
+	}
+	overrides := gachaRequest.Overrides
+	return overrides == nil || (overrides.Pricing == nil && overrides.Policies == nil && overrides.Plan == nil)
+}
+
+func mapGachaPricingFromModel(pricingModel model.Pricing) gacha.Pricing {
+	return gacha.Pricing{
+		PricePerGacha:           pricingModel.PricePerGacha,
+		Discount:                pricingModel.Discount,
+		DiscountTrigger:         pricingModel.DiscountTrigger,
+		DiscountedPricePerGacha: pricingModel.DiscountedPricePerGacha,
+	}
+}
+
+func mapGachaPoliciesFromModel(policiesModel model.Policies) gacha.Policies {
+	var pityItem gacha.Item
+	if policiesModel.Pity && policiesModel.PityItemID != nil {
+		pityItem = gacha.Item{ID: *policiesModel.PityItemID}
+	}
+	return gacha.Policies{
+		Pity:        policiesModel.Pity,
+		PityTrigger: policiesModel.PityTrigger,
+		PityItem:    &pityItem,
+	}
+}
+
+func mapGachaPlanFromModel(planModel model.Plan) (gacha.Plan, error) {
 	wantedItems := make(map[uint]int)
-	if gachaRequest.Plan.ItemGoals {
-		for _, wantedItem := range gachaRequest.Plan.WantedItems {
-			wantedItems[wantedItem.ID] = int(wantedItem.Number)
+	if planModel.ItemGoals {
+		itemNumberMap, err := toMap(planModel.WantedItemsJSON)
+		if err != nil {
+			return gacha.Plan{}, err
+		}
+		for itemID, itemNumber := range itemNumberMap {
+			wantedItems[itemID] = int(itemNumber)
 		}
 	}
 	wantedTiers := make(map[uint]int)
-	if gachaRequest.Plan.TierGoals {
-		for _, wantedTier := range gachaRequest.Plan.WantedTiers {
-			wantedTiers[wantedTier.ID] = int(wantedTier.Number)
+	if planModel.TierGoals {
+		tierNumberMap, err := toMap(planModel.WantedTiersJSON)
+		if err != nil {
+			return gacha.Plan{}, err
 		}
+		for tierID, tierNumber := range tierNumberMap {
+			wantedTiers[tierID] = int(tierNumber)
+		}
+	}
+	goal, err := mapGoalFromModel(planModel)
+	if err != nil {
+		return gacha.Plan{}, err
+	}
+	stopStrategies, err := mapStopStrategiesFromModel(planModel)
+	if err != nil {
+		return gacha.Plan{}, err
+	}
+	return gacha.Plan{
+		Budget:               planModel.Budget,
+		MaxConsecutiveGachas: planModel.MaxConsecutiveGachas,
+		ItemGoals:            planModel.ItemGoals,
+		WantedItems:          wantedItems,
+		TierGoals:            planModel.TierGoals,
+		WantedTiers:          wantedTiers,
+		Goal:                 goal,
+		StopStrategies:       stopStrategies,
+		UseShop:              planModel.UseShop,
+	}, nil
+}
+
+func mapGoalFromModel(planModel model.Plan) (*gacha.Goal, error) {
+	if len(planModel.GoalJSON) == 0 {
+		return nil, nil
+	}
+	var goal *gacha.Goal
+	if err := json.Unmarshal(planModel.GoalJSON, &goal); err != nil {
+		return nil, err
 	}
+	return goal, nil
+}
+
+func mapStopStrategiesFromModel(planModel model.Plan) ([]gacha.StopStrategyConfig, error) {
+	stopStrategies := make([]gacha.StopStrategyConfig, 0)
+	if len(planModel.StopStrategiesJSON) == 0 {
+		return stopStrategies, nil
+	}
+	if err := json.Unmarshal(planModel.StopStrategiesJSON, &stopStrategies); err != nil {
+		return nil, err
+	}
+	return stopStrategies, nil
+}
+
+func mapGachaPricing(pricing Pricing) gacha.Pricing {
+	return gacha.Pricing{
+		PricePerGacha:           pricing.PricePerGacha,
+		Discount:                pricing.Discount,
+		DiscountTrigger:         pricing.DiscountTrigger,
+		DiscountedPricePerGacha: pricing.DiscountedPricePerGacha,
+	}
+}
+
+func mapGachaPolicies(policies Policies) gacha.Policies {
 	var pityItem gacha.Item
-	if gachaRequest.Policies.Pity && gachaRequest.Policies.PityItem != nil {
-		pityItem = gacha.Item{ID: gachaRequest.Policies.PityItem.ID}
+	if policies.Pity && policies.PityItem != nil {
+		pityItem = gacha.Item{ID: policies.PityItem.ID}
 	}
-	return gacha.Request{
-		Tiers:         tiers,
-		ItemsIncluded: gachaRequest.ItemsIncluded,
-		Pricing: gacha.Pricing{
-			PricePerGacha:           gachaRequest.Pricing.PricePerGacha,
-			Discount:                gachaRequest.Pricing.Discount,
-			DiscountTrigger:         gachaRequest.Pricing.DiscountTrigger,
-			DiscountedPricePerGacha: gachaRequest.Pricing.DiscountedPricePerGacha,
-		},
-		Policies: gacha.Policies{
-			Pity:        gachaRequest.Policies.Pity,
-			PityTrigger: gachaRequest.Policies.PityTrigger,
-			PityItem:    &pityItem,
-		},
-		Plan: gacha.Plan{
-			Budget:               gachaRequest.Plan.Budget,
-			MaxConsecutiveGachas: gachaRequest.Plan.MaxConsecutiveGachas,
-			ItemGoals:            gachaRequest.Plan.ItemGoals,
-			WantedItems:          wantedItems,
-			TierGoals:            gachaRequest.Plan.TierGoals,
-			WantedTiers:          wantedTiers,
-		},
-		GetItemCount: func(tierID uint) (int64, error) {
-			var count int64
-			if err := model.DB.
-				Model(&model.Item{}).
-				Where("tier_id", tierID).
-				Count(&count).
-				Error; err != nil {
-				return -1, err
-			}
-			return count, nil
-		},
-		GetItemFromIndex: func(tierID uint, index int) (*gacha.Item, error) {
-			var item model.Item
-			if err := model.DB.
-				Model(&model.Item{}).
-				Where("tier_id", tierID).
-				Offset(index).
-				Preload("Tier").
-				First(&item).
-				Error; err != nil {
-				return nil, err
-			}
-			return &gacha.Item{
-				ID:   item.ID,
-				Tier: &gacha.Tier{ID: item.Tier.ID},
-			}, nil
-		},
-		GetItemFromID: func(itemID uint) (*gacha.Item, error) {
-			var item model.Item
-			if err := model.DB.
-				Preload("Tier").
-				First(&item, "items.id=?", itemID).
-				Error; err != nil {
-				return nil, err
-			}
-			return &gacha.Item{
-				ID:   item.ID,
-				Tier: &gacha.Tier{ID: item.Tier.ID},
-			}, nil
-		},
-		GetItemCountFromIDs: func(itemIDs []uint) (int64, error) {
-			var count int64
-			if err := model.DB.
-				Model(&model.Item{}).
-				Where("id IN ?", itemIDs).
-				Count(&count).
-				Error; err != nil {
-				return -1, err
-			}
-			return count, nil
-		},
-		GetTierCountFromIDs: func(tierIDs []uint) (int64, error) {
-			var count int64
-			if err := model.DB.
-				Model(&model.Tier{}).
-				Where("id IN ?", tierIDs).
-				Count(&count).
-				Error; err != nil {
-				return -1, err
-			}
-			return count, nil
-		},
+	return gacha.Policies{
+		Pity:        policies.Pity,
+		PityTrigger: policies.PityTrigger,
+		PityItem:    &pityItem,
+	}
+}
+
+func mapGachaPlan(plan Plan) gacha.Plan {
+	wantedItems := make(map[uint]int)
+	if plan.ItemGoals {
+		for _, wantedItem := range plan.WantedItems {
+			wantedItems[wantedItem.ID] = int(wantedItem.Number)
+		}
+	}
+	wantedTiers := make(map[uint]int)
+	if plan.TierGoals {
+		for _, wantedTier := range plan.WantedTiers {
+			wantedTiers[wantedTier.ID] = int(wantedTier.Number)
+		}
+	}
+	return gacha.Plan{
+		Budget:               plan.Budget,
+		MaxConsecutiveGachas: plan.MaxConsecutiveGachas,
+		ItemGoals:            plan.ItemGoals,
+		WantedItems:          wantedItems,
+		TierGoals:            plan.TierGoals,
+		WantedTiers:          wantedTiers,
+		Goal:                 plan.Goal,
+		StopStrategies:       plan.StopStrategies,
+		UseShop:              plan.UseShop,
 	}
 }
 
 func mapResultModel(
 	result gacha.Result,
+	luckPercentile *float64,
 	request gacha.Request,
 	gachaRequest GachaRequest,
 	c *gin.Context,
@@ -436,20 +786,38 @@ func mapResultModel(
 	if err != nil {
 		return nil, err
 	}
+	pullsJSON, err := json.Marshal(result.Pulls)
+	if err != nil {
+		return nil, err
+	}
+	shopPurchasesJSON, err := json.Marshal(result.ShopPurchases)
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now()
 	userID, ok := getUserID(c)
 	if !ok {
 		return nil, errors.New("failed to get userID")
 	}
 	return &model.Result{
-		Request:       datatypes.JSON(requestJSON),
-		ItemIDs:       datatypes.JSON(itemIDsJSON),
-		GoalsAchieved: result.GoalsAchieved,
-		MoneySpent:    result.MoneySpent,
-		Time:          now,
-		GameTitleID:   gachaRequest.GameTitle.ID,
-		UserID:        userID,
-		Public:        false,
+		Request:              datatypes.JSON(requestJSON),
+		ItemIDs:              datatypes.JSON(itemIDsJSON),
+		Pulls:                datatypes.JSON(pullsJSON),
+		GoalsAchieved:        result.GoalsAchieved,
+		GoalsAchievedViaShop: result.GoalsAchievedViaShop,
+		MoneySpent:           result.MoneySpent,
+		Duplicates:           result.Duplicates,
+		ConversionCurrency:   result.ConversionCurrency,
+		StopReason:           result.StopReason,
+		ShopPurchases:        datatypes.JSON(shopPurchasesJSON),
+		LuckPercentile:       luckPercentile,
+		Time:                 now,
+		GameTitleID:          gachaRequest.GameTitle.ID,
+		BannerID:             gachaRequest.BannerID,
+		PresetID:             gachaRequest.PresetID,
+		PresetUnmodified:     isUnmodifiedPresetRun(gachaRequest),
+		UserID:               userID,
+		Public:               false,
 	}, nil
 }
 
@@ -462,29 +830,49 @@ func mapResultResponse(
 		return nil, err
 	}
 
-	uniqueItemIDs, err := makeUniqueItemIDs(resultModel)
+	itemIDs, err := getResultItemIDs(resultModel)
 	if err != nil {
 		return nil, err
 	}
-	itemsModel, err := getItemsModelByIDs(uniqueItemIDs)
+
+	pulls := make([]gacha.Pull, 0)
+	if len(resultModel.Pulls) > 0 {
+		if err := json.Unmarshal(resultModel.Pulls, &pulls); err != nil {
+			return nil, err
+		}
+	}
+
+	shopPurchases := make([]gacha.ShopPurchase, 0)
+	if len(resultModel.ShopPurchases) > 0 {
+		if err := json.Unmarshal(resultModel.ShopPurchases, &shopPurchases); err != nil {
+			return nil, err
+		}
+	}
+
+	itemCounts := countItemIDs(itemIDs)
+	obtainedItemIDs := itemIDs
+	for _, shopPurchase := range shopPurchases {
+		itemCounts[shopPurchase.ItemID] += shopPurchase.Number
+		obtainedItemIDs = append(obtainedItemIDs, shopPurchase.ItemID)
+	}
+	itemsModel, err := getItemsModelByIDs(makeUniqueItemIDs(obtainedItemIDs))
 	if err != nil {
 		return nil, err
 	}
 	items := mapItems(itemsModel, c)
+	tierCounts := countTierIDs(items, itemCounts)
 
-	remainingWantedItemIDs := makeRemainingWantedItemIDs(request, items)
-	remainingWantedItemsModel, err := getItemsModelByIDs(remainingWantedItemIDs)
+	wantedItemsModel, err := getItemsModelByIDs(makeWantedItemIDs(request))
 	if err != nil {
 		return nil, err
 	}
-	remainingWantedItems := mapItems(remainingWantedItemsModel, c)
+	wantedItemProgress := makeWantedItemProgress(request, mapItems(wantedItemsModel, c), itemCounts)
 
-	remainingWantedTierIDs := makeRemainingWantedTierIDs(request, items)
-	remainingWantedTiersModel, err := getTiersModelByIDs(remainingWantedTierIDs)
+	wantedTiersModel, err := getTiersModelByIDs(makeWantedTierIDs(request))
 	if err != nil {
 		return nil, err
 	}
-	remainingWantedTiers := mapTiers(remainingWantedTiersModel, c)
+	wantedTierProgress := makeWantedTierProgress(request, mapTiers(wantedTiersModel, c), tierCounts)
 
 	result, err := mapResult(*resultModel, c)
 	if err != nil {
@@ -494,61 +882,126 @@ func mapResultResponse(
 	return &ResultResponse{
 		Result:               *result,
 		Items:                items,
-		RemainingWantedItems: remainingWantedItems,
-		RemainingWantedTiers: remainingWantedTiers,
+		Pulls:                pulls,
+		ShopPurchases:        shopPurchases,
+		RemainingWantedItems: makeRemainingWantedItems(wantedItemProgress),
+		RemainingWantedTiers: makeRemainingWantedTiers(wantedTierProgress),
+		WantedItemProgress:   wantedItemProgress,
+		WantedTierProgress:   wantedTierProgress,
 	}, nil
 }
 
-func makeUniqueItemIDs(result *model.Result) ([]uint, error) {
-	uniqueItemIDs := make([]uint, 0)
-	itemIDMap := make(map[uint]bool)
+func getResultItemIDs(resultModel *model.Result) ([]uint, error) {
 	var itemIDs []uint
-	if err := json.Unmarshal(result.ItemIDs, &itemIDs); err != nil {
+	if err := json.Unmarshal(resultModel.ItemIDs, &itemIDs); err != nil {
 		return nil, err
 	}
+	return itemIDs, nil
+}
+
+func makeUniqueItemIDs(itemIDs []uint) []uint {
+	uniqueItemIDs := make([]uint, 0)
+	itemIDMap := make(map[uint]bool)
 	for _, itemID := range itemIDs {
 		itemIDMap[itemID] = true
 	}
 	for itemID := range itemIDMap {
 		uniqueItemIDs = append(uniqueItemIDs, itemID)
 	}
-	return uniqueItemIDs, nil
+	return uniqueItemIDs
 }
 
-func makeRemainingWantedItemIDs(request gacha.Request, items []Item) []uint {
-	remainingWantedItemIDs := make([]uint, 0)
+func countItemIDs(itemIDs []uint) map[uint]int {
+	itemCounts := make(map[uint]int)
+	for _, itemID := range itemIDs {
+		itemCounts[itemID]++
+	}
+	return itemCounts
+}
+
+func countTierIDs(items []Item, itemCounts map[uint]int) map[uint]int {
+	tierCounts := make(map[uint]int)
+	for _, item := range items {
+		if item.Tier != nil {
+			tierCounts[item.Tier.ID] += itemCounts[item.ID]
+		}
+	}
+	return tierCounts
+}
+
+func makeWantedItemIDs(request gacha.Request) []uint {
+	wantedItemIDs := make([]uint, 0)
 	if request.Plan.ItemGoals {
 		for wantedItemID := range request.Plan.WantedItems {
-			found := false
-			for _, item := range items {
-				if item.ID == wantedItemID {
-					found = true
-					break
-				}
-			}
-			if !found {
-				remainingWantedItemIDs = append(remainingWantedItemIDs, wantedItemID)
-			}
+			wantedItemIDs = append(wantedItemIDs, wantedItemID)
 		}
 	}
-	return remainingWantedItemIDs
+	return wantedItemIDs
 }
 
-func makeRemainingWantedTierIDs(request gacha.Request, items []Item) []uint {
-	remainingWantedTierIDs := make([]uint, 0)
+func makeWantedTierIDs(request gacha.Request) []uint {
+	wantedTierIDs := make([]uint, 0)
 	if request.Plan.TierGoals {
 		for wantedTierID := range request.Plan.WantedTiers {
-			found := false
-			for _, item := range items {
-				if item.Tier.ID == wantedTierID {
-					found = true
-					break
-				}
-			}
-			if !found {
-				remainingWantedTierIDs = append(remainingWantedTierIDs, wantedTierID)
-			}
-		}
-	}
-	return remainingWantedTierIDs
+			wantedTierIDs = append(wantedTierIDs, wantedTierID)
+		}
+	}
+	return wantedTierIDs
+}
+
+func makeWantedItemProgress(request gacha.Request, wantedItems []Item, itemCounts map[uint]int) []ItemProgress {
+	wantedItemProgress := make([]ItemProgress, 0)
+	for _, wantedItem := range wantedItems {
+		wanted := request.Plan.WantedItems[wantedItem.ID]
+		obtained := itemCounts[wantedItem.ID]
+		wantedItemProgress = append(wantedItemProgress, ItemProgress{
+			Item:      wantedItem,
+			Wanted:    wanted,
+			Obtained:  obtained,
+			Remaining: remainingCount(wanted, obtained),
+		})
+	}
+	return wantedItemProgress
+}
+
+func makeWantedTierProgress(request gacha.Request, wantedTiers []Tier, tierCounts map[uint]int) []TierProgress {
+	wantedTierProgress := make([]TierProgress, 0)
+	for _, wantedTier := range wantedTiers {
+		wanted := request.Plan.WantedTiers[wantedTier.ID]
+		obtained := tierCounts[wantedTier.ID]
+		wantedTierProgress = append(wantedTierProgress, TierProgress{
+			Tier:      wantedTier,
+			Wanted:    wanted,
+			Obtained:  obtained,
+			Remaining: remainingCount(wanted, obtained),
+		})
+	}
+	return wantedTierProgress
+}
+
+func makeRemainingWantedItems(wantedItemProgress []ItemProgress) []Item {
+	remainingWantedItems := make([]Item, 0)
+	for _, progress := range wantedItemProgress {
+		if progress.Remaining > 0 {
+			remainingWantedItems = append(remainingWantedItems, progress.Item)
+		}
+	}
+	return remainingWantedItems
+}
+
+func makeRemainingWantedTiers(wantedTierProgress []TierProgress) []Tier {
+	remainingWantedTiers := make([]Tier, 0)
+	for _, progress := range wantedTierProgress {
+		if progress.Remaining > 0 {
+			remainingWantedTiers = append(remainingWantedTiers, progress.Tier)
+		}
+	}
+	return remainingWantedTiers
+}
+
+func remainingCount(wanted, obtained int) int {
+	if obtained >= wanted {
+		return 0
+	}
+	return wanted - obtained
 }
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"gacha-simulator/gacha"
+	"gacha-simulator/model"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SSEventPull   = "pull"
+	SSEventResult = "result"
+	SSEventError  = "error"
+)
+
+type GoalProgress struct {
+	ID       uint `json:"id"`
+	Wanted   int  `json:"wanted"`
+	Obtained int  `json:"obtained"`
+}
+
+type PullEvent struct {
+	Pull        gacha.Pull     `json:"pull"`
+	MoneySpent  float64        `json:"moneySpent"`
+	WantedItems []GoalProgress `json:"wantedItems"`
+	WantedTiers []GoalProgress `json:"wantedTiers"`
+}
+
+type ResultEvent struct {
+	ResultID       uint     `json:"resultId"`
+	GoalsAchieved  bool     `json:"goalsAchieved"`
+	MoneySpent     float64  `json:"moneySpent"`
+	LuckPercentile *float64 `json:"luckPercentile"`
+	StopReason     string   `json:"stopReason"`
+}
+
+type ErrorEvent struct {
+	Error string `json:"error"`
+}
+
+type goalProgressTracker struct {
+	plan       gacha.Plan
+	itemCounts map[uint]int
+	tierCounts map[uint]int
+}
+
+func PostGachasStream(c *gin.Context) {
+	var gachaRequest GachaRequest
+	c.Bind(&gachaRequest)
+	request, ok, err := resolveGachaRequest(gachaRequest)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	ctx := c.Request.Context()
+	tracker := goalProgressTracker{
+		plan:       request.Plan,
+		itemCounts: make(map[uint]int),
+		tierCounts: make(map[uint]int),
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	request.OnPull = func(pull gacha.Pull) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tracker.add(pull)
+		c.SSEvent(SSEventPull, PullEvent{
+			Pull:        pull,
+			MoneySpent:  pull.CumulativeSpend,
+			WantedItems: tracker.wantedItems(),
+			WantedTiers: tracker.wantedTiers(),
+		})
+		c.Writer.Flush()
+		return nil
+	}
+
+	result, err := gacha.Execute(request)
+	if err != nil {
+		if ctx.Err() == nil {
+			streamError(c, err)
+		}
+		return
+	}
+
+	luckPercentile := gacha.LuckPercentileIfReady(request, result)
+	resultModel, err := mapResultModel(result, luckPercentile, request, gachaRequest, c)
+	if err != nil {
+		streamError(c, err)
+		return
+	}
+	if err := model.DB.Create(resultModel).Error; err != nil {
+		streamError(c, err)
+		return
+	}
+
+	c.SSEvent(SSEventResult, ResultEvent{
+		ResultID:       resultModel.ID,
+		GoalsAchieved:  result.GoalsAchieved,
+		MoneySpent:     result.MoneySpent,
+		LuckPercentile: luckPercentile,
+		StopReason:     result.StopReason,
+	})
+	c.Writer.Flush()
+}
+
+func streamError(c *gin.Context, err error) {
+	c.Error(err)
+	c.SSEvent(SSEventError, ErrorEvent{Error: err.Error()})
+	c.Writer.Flush()
+}
+
+func (tracker *goalProgressTracker) add(pull gacha.Pull) {
+	tracker.itemCounts[pull.ItemID]++
+	tracker.tierCounts[pull.TierID]++
+}
+
+func (tracker *goalProgressTracker) wantedItems() []GoalProgress {
+	if !tracker.plan.ItemGoals {
+		return make([]GoalProgress, 0)
+	}
+	return makeGoalProgress(tracker.plan.WantedItems, tracker.itemCounts)
+}
+
+func (tracker *goalProgressTracker) wantedTiers() []GoalProgress {
+	if !tracker.plan.TierGoals {
+		return make([]GoalProgress, 0)
+	}
+	return makeGoalProgress(tracker.plan.WantedTiers, tracker.tierCounts)
+}
+
+func makeGoalProgress(wanted map[uint]int, counts map[uint]int) []GoalProgress {
+	goalProgress := make([]GoalProgress, 0)
+	for id, number := range wanted {
+		goalProgress = append(goalProgress, GoalProgress{
+			ID:       id,
+			Wanted:   number,
+			Obtained: counts[id],
+		})
+	}
+	sort.Slice(goalProgress, func(i, j int) bool {
+		return goalProgress[i].ID < goalProgress[j].ID
+	})
+	return goalProgress
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/datatypes"
@@ -29,11 +31,57 @@ type Pagination struct {
 	Total        int64       `json:"total"`
 	PageIndex    int         `json:"pageIndex"`
 	PageTotal    int         `json:"pageTotal"`
+	NextCursor   string      `json:"nextCursor,omitempty"`
 	Data         interface{} `json:"data"`
 }
 
+type resultFilter struct {
+	goalsAchieved *bool
+	public        *bool
+	presetID      *uint
+	from          *time.Time
+	to            *time.Time
+}
+
+type resultCursor struct {
+	Sort  string    `json:"sort"`
+	Order string    `json:"order"`
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	ID    uint      `json:"id"`
+}
+
 const ItemSearchLimit = 50
 const CountPerPage = 10
+const MaxCountPerPage = 100
+
+const (
+	SortLuckiest   = "luckiest"
+	SortUnluckiest = "unluckiest"
+	SortRecent     = "recent"
+	SortLuck       = "luck"
+	SortMoneySpent = "moneySpent"
+	SortPulls      = "pulls"
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+var resultSortExpressions = map[string]string{
+	SortRecent:     "results.time",
+	SortLuck:       "COALESCE(results.luck_percentile, -1)",
+	SortMoneySpent: "results.money_spent",
+	SortPulls:      "jsonb_array_length(results.item_ids)",
+}
+
+const (
+	ActiveNow      = "now"
+	ActiveUpcoming = "upcoming"
+	ActivePast     = "past"
+	ActiveAll      = "all"
+)
 
 func GetGameTitles(c *gin.Context) {
 	gameTitlesModel, err := getGameTitlesModel()
@@ -123,13 +171,18 @@ func GetPlans(c *gin.Context) {
 
 func GetPresets(c *gin.Context) {
 	gameTitleSlug := c.Param("gameTitleSlug")
+	active := c.DefaultQuery("active", ActiveAll)
+	if !isValidActive(active) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
 	tiersModel, err := getTiersModel(gameTitleSlug)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
 	tiers := mapTiers(tiersModel, c)
-	presetsModel, err := getPresetsModel(gameTitleSlug)
+	presetsModel, err := getPresetsModel(gameTitleSlug, active, time.Now())
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
@@ -153,13 +206,33 @@ func GetGachas(c *gin.Context) {
 		c.Status(http.StatusBadRequest)
 		return
 	}
-	count := CountPerPage
-	total, err := getTotalResultCount(gameTitleSlug, userID)
+	count, err := getCountPerPage(c)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	sort := c.DefaultQuery("sort", SortRecent)
+	order := c.DefaultQuery("order", OrderDesc)
+	if _, ok := resultSortExpressions[sort]; !ok || (order != OrderAsc && order != OrderDesc) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	filter, err := getResultFilter(c)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	cursor, err := getResultCursor(c, sort, order)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	total, err := getTotalResultCount(gameTitleSlug, userID, filter)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
-	resultsModel, err := getResultsModel(gameTitleSlug, userID, pageIndex, count)
+	resultsModel, err := getResultsModel(gameTitleSlug, userID, filter, sort, order, cursor, pageIndex, count)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
@@ -169,7 +242,75 @@ func GetGachas(c *gin.Context) {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
-	pagination := mapPagination(total, pageIndex, count, results)
+	pagination := mapPagination(total, pageIndex, count, len(results), results)
+	if cursor != nil {
+		index, err := getResultCountBeforeCursor(gameTitleSlug, userID, filter, sort, order, cursor)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		pagination.Index = int(index)
+		pagination.PageIndex = int(index) / count
+	}
+	if len(resultsModel) == count && int64(pagination.Index+len(resultsModel)) < total {
+		nextCursor, err := encodeResultCursor(resultsModel[len(resultsModel)-1], sort, order)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		pagination.NextCursor = nextCursor
+	}
+	c.JSON(http.StatusOK, &pagination)
+}
+
+func GetPublicGachas(c *gin.Context) {
+	gameTitleSlug := c.Param("gameTitleSlug")
+	pageIndex, err := getPageIndex(c)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	sort := c.DefaultQuery("sort", SortRecent)
+	if !isValidPublicSort(sort) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	presetID, err := getUintQuery(c, "presetId")
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	goalsAchieved, err := getBoolQuery(c, "goalsAchieved")
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	scope := publicGameTitleGachas(gameTitleSlug, presetID, goalsAchieved)
+	count := CountPerPage
+	var total int64
+	if err := model.DB.
+		Scopes(scope).
+		Count(&total).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	var resultsModel []model.Result
+	if err := model.DB.
+		Scopes(scope, publicGachasSorted(sort)).
+		Offset(pageIndex * count).
+		Limit(count).
+		Find(&resultsModel).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	results, err := mapResults(resultsModel, c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	pagination := mapPagination(total, pageIndex, count, len(results), results)
 	c.JSON(http.StatusOK, &pagination)
 }
 
@@ -190,7 +331,7 @@ func getGameTitleModel(gameTitleSlug string) (*model.GameTitle, error) {
 	if err := model.DB.
 		Where("slug = ?", gameTitleSlug).
 		Preload("Translations").
-		Find(&gameTitleModel).
+		First(&gameTitleModel).
 		Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -203,7 +344,7 @@ func getGameTitleModel(gameTitleSlug string) (*model.GameTitle, error) {
 func getTiersModel(gameTitleSlug string) ([]model.Tier, error) {
 	var tiersModel []model.Tier
 	if err := model.DB.
-		Joins("JOIN game_titles on game_titles.id=tiers.game_title_id").
+		Joins("JOIN game_titles on game_titles.id=tiers.game_title_id AND game_titles.deleted_at IS NULL").
 		Where("game_titles.slug = ?", gameTitleSlug).
 		Preload("Translations").
 		Find(&tiersModel).
@@ -232,7 +373,7 @@ func getItemsModel(gameTitleSlug string, name string) ([]model.Item, error) {
 	if err := model.DB.
 		Joins("JOIN item_translations on item_translations.item_id=items.id").
 		Joins("JOIN tiers on tiers.id=items.tier_id").
-		Joins("JOIN game_titles on game_titles.id=tiers.game_title_id").
+		Joins("JOIN game_titles on game_titles.id=tiers.game_title_id AND game_titles.deleted_at IS NULL").
 		Where("game_titles.slug", gameTitleSlug).
 		Where(
 			"lower(item_translations.name) LIKE ? OR lower(item_translations.short_name) LIKE ? OR lower(item_translations.short_name_alt) LIKE ?",
@@ -269,7 +410,7 @@ func getItemModel(itemID uint) (*model.Item, error) {
 func getPricingsModel(gameTitleSlug string) ([]model.Pricing, error) {
 	var pricingsModel []model.Pricing
 	if err := model.DB.
-		Joins("JOIN game_titles on game_titles.id=pricings.game_title_id").
+		Joins("JOIN game_titles on game_titles.id=pricings.game_title_id AND game_titles.deleted_at IS NULL").
 		Where("game_titles.slug = ?", gameTitleSlug).
 		Preload("Translations").
 		Find(&pricingsModel).
@@ -283,7 +424,7 @@ func getPoliciesModel(gameTitleSlug string) ([]model.Policies, error) {
 	var policiesModel []model.Policies
 	if err := model.DB.
 		Joins("LEFT JOIN items on items.id=policies.pity_item_id").
-		Joins("JOIN game_titles on game_titles.id=policies.game_title_id").
+		Joins("JOIN game_titles on game_titles.id=policies.game_title_id AND game_titles.deleted_at IS NULL").
 		Where("game_titles.slug = ?", gameTitleSlug).
 		Preload("PityItem.Tier.Translations").
 		Preload("PityItem.Translations").
@@ -298,7 +439,7 @@ func getPoliciesModel(gameTitleSlug string) ([]model.Policies, error) {
 func getPlansModel(gameTitleSlug string) ([]model.Plan, error) {
 	var plansModel []model.Plan
 	if err := model.DB.
-		Joins("JOIN game_titles on game_titles.id=plans.game_title_id").
+		Joins("JOIN game_titles on game_titles.id=plans.game_title_id AND game_titles.deleted_at IS NULL").
 		Where("game_titles.slug = ?", gameTitleSlug).
 		Preload("Translations").
 		Find(&plansModel).
@@ -308,11 +449,12 @@ func getPlansModel(gameTitleSlug string) ([]model.Plan, error) {
 	return plansModel, nil
 }
 
-func getPresetsModel(gameTitleSlug string) ([]model.Preset, error) {
+func getPresetsModel(gameTitleSlug, active string, now time.Time) ([]model.Preset, error) {
 	var presetsModel []model.Preset
 	if err := model.DB.
-		Joins("JOIN game_titles on game_titles.id=presets.game_title_id").
+		Joins("JOIN game_titles on game_titles.id=presets.game_title_id AND game_titles.deleted_at IS NULL").
 		Where("game_titles.slug = ?", gameTitleSlug).
+		Scopes(scheduledAt("presets", active, now)).
 		Preload("Pricing.Translations").
 		Preload("Pricing").
 		Preload("Policies.PityItem.Tier.Translations").
@@ -329,11 +471,42 @@ func getPresetsModel(gameTitleSlug string) ([]model.Preset, error) {
 	return presetsModel, nil
 }
 
-func getTotalResultCount(gameTitleSlug, userID string) (int64, error) {
+func scheduledAt(table, active string, now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch active {
+		case ActiveNow:
+			return db.
+				Where("("+table+".start_time IS NULL OR "+table+".start_time <= ?)", now).
+				Where("("+table+".end_time IS NULL OR "+table+".end_time > ?)", now).
+				Order(table + ".start_time DESC NULLS LAST").
+				Order(table + ".id")
+		case ActiveUpcoming:
+			return db.
+				Where(table+".start_time > ?", now).
+				Order(table + ".start_time").
+				Order(table + ".id")
+		case ActivePast:
+			return db.
+				Where(table+".end_time <= ?", now).
+				Order(table + ".end_time DESC").
+				Order(table + ".id")
+		default:
+			return db.
+				Order(table + ".start_time NULLS FIRST").
+				Order(table + ".id")
+		}
+	}
+}
+
+func isValidActive(active string) bool {
+	return active == ActiveNow || active == ActiveUpcoming || active == ActivePast || active == ActiveAll
+}
+
+func getTotalResultCount(gameTitleSlug, userID string, filter resultFilter) (int64, error) {
 	var total int64
 	var resultsModel []model.Result
 	if err := model.DB.
-		Scopes(gameTitleGachasByUser(&resultsModel, gameTitleSlug, userID)).
+		Scopes(gameTitleGachasByUser(&resultsModel, gameTitleSlug, userID), resultsFiltered(filter)).
 		Count(&total).
 		Error; err != nil {
 		return -1, err
@@ -341,13 +514,27 @@ func getTotalResultCount(gameTitleSlug, userID string) (int64, error) {
 	return total, nil
 }
 
-func getResultsModel(gameTitleSlug, userID string, pageIndex, count int) ([]model.Result, error) {
-	offset := pageIndex * count
+func getResultsModel(
+	gameTitleSlug, userID string,
+	filter resultFilter,
+	sort, order string,
+	cursor *resultCursor,
+	pageIndex, count int,
+) ([]model.Result, error) {
 	var resultsModel []model.Result
-	if err := model.DB.
-		Scopes(gameTitleGachasByUser(&resultsModel, gameTitleSlug, userID)).
-		Order("results.time desc").
-		Offset(offset).
+	expression := resultSortExpressions[sort]
+	db := model.DB.Scopes(gameTitleGachasByUser(&resultsModel, gameTitleSlug, userID), resultsFiltered(filter))
+	if cursor != nil {
+		operator := "<"
+		if order == OrderAsc {
+			operator = ">"
+		}
+		db = db.Scopes(resultsFromCursor(expression, operator, cursor))
+	} else {
+		db = db.Offset(pageIndex * count)
+	}
+	if err := db.
+		Order(fmt.Sprintf("%s %s, results.id %s", expression, order, order)).
 		Limit(count).
 		Find(&resultsModel).
 		Error; err != nil {
@@ -356,14 +543,172 @@ func getResultsModel(gameTitleSlug, userID string, pageIndex, count int) ([]mode
 	return resultsModel, nil
 }
 
+func getResultCountBeforeCursor(
+	gameTitleSlug, userID string,
+	filter resultFilter,
+	sort, order string,
+	cursor *resultCursor,
+) (int64, error) {
+	var resultsModel []model.Result
+	var index int64
+	operator := ">="
+	if order == OrderAsc {
+		operator = "<="
+	}
+	if err := model.DB.
+		Scopes(
+			gameTitleGachasByUser(&resultsModel, gameTitleSlug, userID),
+			resultsFiltered(filter),
+			resultsFromCursor(resultSortExpressions[sort], operator, cursor),
+		).
+		Count(&index).
+		Error; err != nil {
+		return -1, err
+	}
+	return index, nil
+}
+
+func resultsFromCursor(expression, operator string, cursor *resultCursor) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		var value interface{} = cursor.Value
+		if cursor.Sort == SortRecent {
+			value = cursor.Time
+		}
+		return db.Where(fmt.Sprintf("(%s, results.id) %s (?, ?)", expression, operator), value, cursor.ID)
+	}
+}
+
+func resultsFiltered(filter resultFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.goalsAchieved != nil {
+			db = db.Where("results.goals_achieved = ?", *filter.goalsAchieved)
+		}
+		if filter.public != nil {
+			db = db.Where("results.public = ?", *filter.public)
+		}
+		if filter.presetID != nil {
+			db = db.Where("results.preset_id = ?", *filter.presetID)
+		}
+		if filter.from != nil {
+			db = db.Where("results.time >= ?", *filter.from)
+		}
+		if filter.to != nil {
+			db = db.Where("results.time < ?", *filter.to)
+		}
+		return db
+	}
+}
+
+func getResultFilter(c *gin.Context) (resultFilter, error) {
+	var filter resultFilter
+	var err error
+	if filter.goalsAchieved, err = getBoolQuery(c, "goalsAchieved"); err != nil {
+		return filter, err
+	}
+	if filter.public, err = getBoolQuery(c, "public"); err != nil {
+		return filter, err
+	}
+	if filter.presetID, err = getUintQuery(c, "presetId"); err != nil {
+		return filter, err
+	}
+	if filter.from, err = getTimeQuery(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.to, err = getTimeQuery(c, "to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func getResultCursor(c *gin.Context, sort, order string) (*resultCursor, error) {
+	cursorStr := c.Query("cursor")
+	if len(cursorStr) == 0 {
+		return nil, nil
+	}
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(cursorStr)
+	if err != nil {
+		return nil, err
+	}
+	var cursor resultCursor
+	if err := json.Unmarshal(cursorJSON, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Sort != sort || cursor.Order != order {
+		return nil, errors.New("cursor does not match sort order")
+	}
+	return &cursor, nil
+}
+
+func encodeResultCursor(resultModel model.Result, sort, order string) (string, error) {
+	cursor := resultCursor{
+		Sort:  sort,
+		Order: order,
+		ID:    resultModel.ID,
+	}
+	switch sort {
+	case SortRecent:
+		cursor.Time = resultModel.Time
+	case SortLuck:
+		cursor.Value = -1
+		if resultModel.LuckPercentile != nil {
+			cursor.Value = *resultModel.LuckPercentile
+		}
+	case SortMoneySpent:
+		cursor.Value = resultModel.MoneySpent
+	case SortPulls:
+		itemIDs, err := getResultItemIDs(&resultModel)
+		if err != nil {
+			return "", err
+		}
+		cursor.Value = float64(len(itemIDs))
+	}
+	cursorJSON, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJSON), nil
+}
+
 func gameTitleGachasByUser(resultsModel *[]model.Result, gameTitleSlug, userID string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
 		return db.Model(resultsModel).
-			Joins("JOIN game_titles on game_titles.id=results.game_title_id").
+			Joins("JOIN game_titles on game_titles.id=results.game_title_id AND game_titles.deleted_at IS NULL").
 			Where("game_titles.slug = ? AND results.user_id = ?", gameTitleSlug, userID)
 	}
 }
 
+func publicGameTitleGachas(gameTitleSlug string, presetID *uint, goalsAchieved *bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Model(&model.Result{}).
+			Joins("JOIN game_titles on game_titles.id=results.game_title_id AND game_titles.deleted_at IS NULL").
+			Where("game_titles.slug = ? AND results.public = ?", gameTitleSlug, true)
+		if presetID != nil {
+			db = db.Where("results.preset_id = ? AND results.preset_unmodified = ?", *presetID, true)
+		}
+		if goalsAchieved != nil {
+			db = db.Where("results.goals_achieved = ?", *goalsAchieved)
+		}
+		return db
+	}
+}
+
+func publicGachasSorted(sort string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch sort {
+		case SortLuckiest:
+			return db.Order("results.goals_achieved desc, jsonb_array_length(results.item_ids) asc, results.money_spent asc, results.id asc")
+		case SortUnluckiest:
+			return db.Order("results.goals_achieved asc, jsonb_array_length(results.item_ids) desc, results.money_spent desc, results.id asc")
+		default:
+			return db.Order("results.time desc, results.id desc")
+		}
+	}
+}
+
+func isValidPublicSort(sort string) bool {
+	return sort == SortLuckiest || sort == SortUnluckiest || sort == SortRecent
+}
+
 func mapGameTitle(gameTitleModel model.GameTitle, c *gin.Context) *GameTitle {
 	preferred := getPreferredLanguage(c)
 	i := getTranslationIndex(preferred, gameTitleModel)
@@ -391,11 +736,12 @@ func mapTier(tierModel model.Tier, c *gin.Context) *Tier {
 	preferred := getPreferredLanguage(c)
 	i := getTranslationIndex(preferred, tierModel)
 	return &Tier{
-		ID:        tierModel.ID,
-		Ratio:     tierModel.Ratio,
-		ImageURL:  tierModel.ImageURL,
-		Name:      tierModel.Translations[i].Name,
-		ShortName: tierModel.Translations[i].ShortName,
+		ID:              tierModel.ID,
+		Ratio:           tierModel.Ratio,
+		ConversionValue: tierModel.ConversionValue,
+		ImageURL:        tierModel.ImageURL,
+		Name:            tierModel.Translations[i].Name,
+		ShortName:       tierModel.Translations[i].ShortName,
 	}
 }
 
@@ -415,6 +761,7 @@ func mapItem(itemModel model.Item, c *gin.Context) *Item {
 	return &Item{
 		ID:           itemModel.ID,
 		Ratio:        itemModel.Ratio,
+		ShopPrice:    itemModel.ShopPrice,
 		ImageURL:     itemModel.ImageURL,
 		Tier:         tier,
 		Name:         itemModel.Translations[i].Name,
@@ -490,6 +837,14 @@ func mapPlan(planModel model.Plan, c *gin.Context) (*Plan, error) {
 	if err != nil {
 		return nil, err
 	}
+	goal, err := mapGoalFromModel(planModel)
+	if err != nil {
+		return nil, err
+	}
+	stopStrategies, err := mapStopStrategiesFromModel(planModel)
+	if err != nil {
+		return nil, err
+	}
 	return &Plan{
 		ID:                   planModel.ID,
 		Budget:               planModel.Budget,
@@ -498,6 +853,9 @@ func mapPlan(planModel model.Plan, c *gin.Context) (*Plan, error) {
 		WantedItems:          wantedItems,
 		TierGoals:            planModel.TierGoals,
 		WantedTiers:          wantedTiers,
+		Goal:                 goal,
+		StopStrategies:       stopStrategies,
+		UseShop:              planModel.UseShop,
 		Name:                 planModel.Translations[i].Name,
 	}, nil
 }
@@ -582,6 +940,8 @@ func mapPreset(presetModel model.Preset, c *gin.Context) (*Preset, error) {
 		Pricing:     pricing,
 		Policies:    policies,
 		Plan:        plan,
+		StartTime:   presetModel.StartTime,
+		EndTime:     presetModel.EndTime,
 		Name:        presetModel.Translations[i].Name,
 		Description: presetModel.Translations[i].Description,
 	}, nil
@@ -613,15 +973,24 @@ func mapResult(resultModel model.Result, c *gin.Context) (*Result, error) {
 		return nil, err
 	}
 	return &Result{
-		ID:            resultModel.ID,
-		UserID:        resultModel.UserID,
-		Public:        resultModel.Public,
-		Request:       request,
-		ItemIDs:       itemIDs,
-		GoalsAchieved: resultModel.GoalsAchieved,
-		MoneySpent:    resultModel.MoneySpent,
-		Time:          resultModel.Time,
-		GameTitle:     gameTitle,
+		ID:                   resultModel.ID,
+		UserID:               resultModel.UserID,
+		Public:               resultModel.Public,
+		Pinned:               resultModel.Pinned,
+		Request:              request,
+		ItemIDs:              itemIDs,
+		GoalsAchieved:        resultModel.GoalsAchieved,
+		GoalsAchievedViaShop: resultModel.GoalsAchievedViaShop,
+		MoneySpent:           resultModel.MoneySpent,
+		Duplicates:           resultModel.Duplicates,
+		ConversionCurrency:   resultModel.ConversionCurrency,
+		StopReason:           resultModel.StopReason,
+		LuckPercentile:       resultModel.LuckPercentile,
+		Time:                 resultModel.Time,
+		GameTitle:            gameTitle,
+		BannerID:             resultModel.BannerID,
+		PresetID:             resultModel.PresetID,
+		PresetUnmodified:     resultModel.PresetUnmodified,
 	}, nil
 }
 
@@ -637,15 +1006,15 @@ func mapResults(resultsModel []model.Result, c *gin.Context) ([]Result, error) {
 	return results, nil
 }
 
-func mapPagination(total int64, pageIndex, count int, results []Result) *Pagination {
+func mapPagination(total int64, pageIndex, count, dataCount int, data interface{}) *Pagination {
 	return &Pagination{
 		Index:        pageIndex * count,
-		Count:        len(results),
+		Count:        dataCount,
 		CountPerPage: count,
 		Total:        total,
 		PageIndex:    pageIndex,
 		PageTotal:    int(math.Ceil(float64(total) / float64(count))),
-		Data:         results,
+		Data:         data,
 	}
 }
 
@@ -669,6 +1038,46 @@ func getPageIndex(c *gin.Context) (int, error) {
 	return pageIndex, nil
 }
 
+func getCountPerPage(c *gin.Context) (int, error) {
+	countStr := c.Query("count")
+	if len(countStr) == 0 {
+		return CountPerPage, nil
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return -1, err
+	}
+	if count <= 0 || count > MaxCountPerPage {
+		return -1, errors.New("count per page out of range")
+	}
+	return count, nil
+}
+
+func getUintQuery(c *gin.Context, key string) (*uint, error) {
+	valueStr := c.Query(key)
+	if len(valueStr) == 0 {
+		return nil, nil
+	}
+	value, err := strconv.ParseUint(valueStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	result := uint(value)
+	return &result, nil
+}
+
+func getBoolQuery(c *gin.Context, key string) (*bool, error) {
+	valueStr := c.Query(key)
+	if len(valueStr) == 0 {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
 type ByTierAndShortName []Item
 
 func (a ByTierAndShortName) Len() int {
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"gacha-simulator/job"
+	"gacha-simulator/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Job struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	NextRun  *time.Time `json:"nextRun"`
+	LastRun  *JobRun    `json:"lastRun"`
+}
+
+type JobRun struct {
+	ID          uint       `json:"id"`
+	ScheduledAt *time.Time `json:"scheduledAt"`
+	StartedAt   time.Time  `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+	DurationMs  int64      `json:"durationMs"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error"`
+}
+
+func GetJobs(c *gin.Context) {
+	var jobRunsModel []model.JobRun
+	if err := model.DB.
+		Raw("SELECT DISTINCT ON (name) * FROM job_runs ORDER BY name, started_at DESC").
+		Scan(&jobRunsModel).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	jobs := mapJobs(job.Jobs(), jobRunsModel)
+	c.JSON(http.StatusOK, &jobs)
+}
+
+func mapJobs(jobInfos []job.JobInfo, jobRunsModel []model.JobRun) []Job {
+	lastRuns := make(map[string]model.JobRun)
+	for _, jobRunModel := range jobRunsModel {
+		lastRuns[jobRunModel.Name] = jobRunModel
+	}
+	jobs := make([]Job, 0)
+	for _, jobInfo := range jobInfos {
+		j := Job{
+			Name:     jobInfo.Name,
+			Schedule: jobInfo.Spec,
+		}
+		if !jobInfo.NextRun.IsZero() {
+			nextRun := jobInfo.NextRun
+			j.NextRun = &nextRun
+		}
+		if jobRunModel, ok := lastRuns[jobInfo.Name]; ok {
+			j.LastRun = mapJobRun(jobRunModel)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+func mapJobRun(jobRunModel model.JobRun) *JobRun {
+	return &JobRun{
+		ID:          jobRunModel.ID,
+		ScheduledAt: jobRunModel.ScheduledAt,
+		StartedAt:   jobRunModel.StartedAt,
+		FinishedAt:  jobRunModel.FinishedAt,
+		DurationMs:  jobRunModel.DurationMs,
+		Status:      jobRunModel.Status,
+		Error:       jobRunModel.Error,
+	}
+}
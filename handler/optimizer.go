@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"gacha-simulator/gacha"
+	"gacha-simulator/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DefaultOptimizationRuns = 1000
+const MaxOptimizationRuns = 2000
+const MaxOptimizationTotalRuns = 10000
+
+type BudgetOptimizationRequest struct {
+	GachaRequest
+	TargetProbability float64 `json:"targetProbability"`
+	Runs              int     `json:"runs"`
+	ComparePricings   bool    `json:"comparePricings"`
+}
+
+type BudgetOptimization struct {
+	gacha.BudgetOptimization
+	Pricing *Pricing `json:"pricing"`
+}
+
+type BudgetOptimizationResponse struct {
+	Best          *BudgetOptimization  `json:"best"`
+	Optimizations []BudgetOptimization `json:"optimizations"`
+}
+
+func PostBudgetOptimization(c *gin.Context) {
+	var optimizationRequest BudgetOptimizationRequest
+	c.Bind(&optimizationRequest)
+	if optimizationRequest.TargetProbability <= 0 || optimizationRequest.TargetProbability > 1 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	runs := optimizationRequest.Runs
+	if runs == 0 {
+		runs = DefaultOptimizationRuns
+	}
+	if runs < 0 || runs > MaxOptimizationRuns {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	request, ok, err := resolveGachaRequest(optimizationRequest.GachaRequest)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !ok || !request.Plan.HasGoals() {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	ctx := c.Request.Context()
+	optimizations := make([]BudgetOptimization, 0)
+	if optimizationRequest.ComparePricings {
+		pricingsModel, err := getPricingsModelByGameTitleID(optimizationRequest.GameTitle.ID)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if runs*len(pricingsModel) > MaxOptimizationTotalRuns {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		for _, pricingModel := range pricingsModel {
+			pricingRequest := request
+			pricingRequest.Pricing = mapGachaPricingFromModel(pricingModel)
+			optimization, err := gacha.OptimizeBudget(ctx, pricingRequest, optimizationRequest.TargetProbability, runs)
+			if err != nil {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			optimizations = append(optimizations, BudgetOptimization{
+				BudgetOptimization: optimization,
+				Pricing:            mapPricing(pricingModel, c),
+			})
+		}
+	} else {
+		optimization, err := gacha.OptimizeBudget(ctx, request, optimizationRequest.TargetProbability, runs)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		optimizations = append(optimizations, BudgetOptimization{
+			BudgetOptimization: optimization,
+		})
+	}
+
+	c.JSON(http.StatusOK, BudgetOptimizationResponse{
+		Best:          getBestBudgetOptimization(optimizations),
+		Optimizations: optimizations,
+	})
+}
+
+func getPricingsModelByGameTitleID(gameTitleID uint) ([]model.Pricing, error) {
+	var pricingsModel []model.Pricing
+	if err := model.DB.
+		Where("game_title_id = ?", gameTitleID).
+		Preload("Translations").
+		Order("id").
+		Find(&pricingsModel).
+		Error; err != nil {
+		return nil, err
+	}
+	return pricingsModel, nil
+}
+
+func getBestBudgetOptimization(optimizations []BudgetOptimization) *BudgetOptimization {
+	var best *BudgetOptimization
+	for i := range optimizations {
+		if !optimizations[i].Achievable {
+			continue
+		}
+		if best == nil || optimizations[i].Budget < best.Budget {
+			best = &optimizations[i]
+		}
+	}
+	return best
+}
@@ -0,0 +1,340 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"gacha-simulator/gacha"
+	"gacha-simulator/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const MaxSessionPullCount = 100
+
+type GachaSession struct {
+	ID            uint           `json:"id"`
+	Pulls         []gacha.Pull   `json:"pulls"`
+	Count         int            `json:"count"`
+	MoneySpent    float64        `json:"moneySpent"`
+	GoalsAchieved bool           `json:"goalsAchieved"`
+	Finished      bool           `json:"finished"`
+	StopReason    string         `json:"stopReason"`
+	WantedItems   []GoalProgress `json:"wantedItems"`
+	WantedTiers   []GoalProgress `json:"wantedTiers"`
+}
+
+type resumedGachaSession struct {
+	model        model.GachaSession
+	gachaRequest GachaRequest
+	request      gacha.Request
+	rng          *gacha.SeededRNG
+	session      *gacha.Session
+}
+
+func PostGachaSession(c *gin.Context) {
+	var gachaRequest GachaRequest
+	c.Bind(&gachaRequest)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	request, ok, err := resolveGachaRequest(gachaRequest)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	seed, err := generateSessionSeed()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	request.RNG = gacha.NewSeededRNG(seed)
+	session, err := gacha.NewSession(request)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	gachaRequestJSON, err := json.Marshal(gachaRequest)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	stateJSON, err := json.Marshal(session.State())
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	gachaSessionModel := model.GachaSession{
+		UserID:       userID,
+		GameTitleID:  gachaRequest.GameTitle.ID,
+		GachaRequest: datatypes.JSON(gachaRequestJSON),
+		Request:      datatypes.JSON(requestJSON),
+		State:        datatypes.JSON(stateJSON),
+		RNGState:     int64(seed),
+	}
+	if err := model.DB.Create(&gachaSessionModel).Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapGachaSession(gachaSessionModel.ID, session, request.Plan, session.Result().Pulls))
+}
+
+func GetGachaSession(c *gin.Context) {
+	gachaSessionID := c.Param("sessionID")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	resumed, err := resumeGachaSession(model.DB, gachaSessionID, userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if resumed == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, mapGachaSession(resumed.model.ID, resumed.session, resumed.request.Plan, resumed.session.Result().Pulls))
+}
+
+func PostGachaSessionPull(c *gin.Context) {
+	gachaSessionID := c.Param("sessionID")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "1"))
+	if err != nil || count <= 0 || count > MaxSessionPullCount {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	tx := model.DB.Begin()
+	resumed, err := resumeGachaSession(tx.Clauses(clause.Locking{Strength: "UPDATE"}), gachaSessionID, userID)
+	if err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if resumed == nil {
+		tx.Rollback()
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if resumed.session.Finished() {
+		tx.Rollback()
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	pulls, err := resumed.session.Pull(count)
+	if err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	stateJSON, err := json.Marshal(resumed.session.State())
+	if err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.
+		Model(&resumed.model).
+		Updates(map[string]interface{}{
+			"state":     datatypes.JSON(stateJSON),
+			"rng_state": int64(resumed.rng.State),
+		}).
+		Error; err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, mapGachaSession(resumed.model.ID, resumed.session, resumed.request.Plan, pulls))
+}
+
+func PostGachaSessionFinalize(c *gin.Context) {
+	gachaSessionID := c.Param("sessionID")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	resumed, err := resumeGachaSession(model.DB, gachaSessionID, userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if resumed == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	result := resumed.session.Result()
+	request := resumed.request
+	request.RNG = nil
+	luckPercentile := gacha.LuckPercentileIfReady(request, result)
+	resultModel, err := mapResultModel(result, luckPercentile, request, resumed.gachaRequest, c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	tx := model.DB.Begin()
+	var lockedModel model.GachaSession
+	if err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", gachaSessionID, userID).
+		First(&lockedModel).
+		Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if lockedModel.RNGState != resumed.model.RNGState || !lockedModel.UpdatedAt.Equal(resumed.model.UpdatedAt) {
+		tx.Rollback()
+		c.Status(http.StatusConflict)
+		return
+	}
+	if err := tx.Create(resultModel).Error; err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Delete(&resumed.model).Error; err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	resultResponse, err := mapResultResponse(resultModel, c)
+	if err != nil {
+		tx.Rollback()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, resultResponse)
+}
+
+func DeleteGachaSession(c *gin.Context) {
+	gachaSessionID := c.Param("sessionID")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	tx := model.DB.
+		Where("id = ? AND user_id = ?", gachaSessionID, userID).
+		Delete(&model.GachaSession{})
+	if err := tx.Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func resumeGachaSession(db *gorm.DB, gachaSessionID, userID string) (*resumedGachaSession, error) {
+	var gachaSessionModel model.GachaSession
+	if err := db.
+		Where("id = ? AND user_id = ?", gachaSessionID, userID).
+		First(&gachaSessionModel).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	gameTitleModel, err := getGameTitleModelByID(gachaSessionModel.GameTitleID)
+	if err != nil {
+		return nil, err
+	}
+	if gameTitleModel == nil {
+		return nil, nil
+	}
+	resumed := resumedGachaSession{model: gachaSessionModel}
+	if err := json.Unmarshal(gachaSessionModel.GachaRequest, &resumed.gachaRequest); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(gachaSessionModel.Request, &resumed.request); err != nil {
+		return nil, err
+	}
+	var state gacha.SessionState
+	if err := json.Unmarshal(gachaSessionModel.State, &state); err != nil {
+		return nil, err
+	}
+	BindGachaRequestLoaders(&resumed.request)
+	resumed.rng = gacha.NewSeededRNG(uint64(gachaSessionModel.RNGState))
+	request := resumed.request
+	request.RNG = resumed.rng
+	session, err := gacha.ResumeSession(request, state)
+	if err != nil {
+		return nil, err
+	}
+	resumed.session = session
+	return &resumed, nil
+}
+
+func generateSessionSeed() (uint64, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func mapGachaSession(gachaSessionID uint, session *gacha.Session, plan gacha.Plan, pulls []gacha.Pull) *GachaSession {
+	result := session.Result()
+	tracker := goalProgressTracker{
+		plan:       plan,
+		itemCounts: make(map[uint]int),
+		tierCounts: make(map[uint]int),
+	}
+	for _, pull := range result.Pulls {
+		tracker.add(pull)
+	}
+	return &GachaSession{
+		ID:            gachaSessionID,
+		Pulls:         pulls,
+		Count:         len(result.Pulls),
+		MoneySpent:    result.MoneySpent,
+		GoalsAchieved: result.GoalsAchieved,
+		Finished:      session.Finished(),
+		StopReason:    result.StopReason,
+		WantedItems:   tracker.wantedItems(),
+		WantedTiers:   tracker.wantedTiers(),
+	}
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"gacha-simulator/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const ShareTokenBytes = 16
+
+type ShareLink struct {
+	Token     string     `json:"token"`
+	ResultID  uint       `json:"resultId"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Revoked   bool       `json:"revoked"`
+}
+
+type PostShareRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+func PostShare(c *gin.Context) {
+	resultID := c.Param("resultID")
+	var postShareRequest PostShareRequest
+	c.Bind(&postShareRequest)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if postShareRequest.ExpiresAt != nil && !postShareRequest.ExpiresAt.After(now) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	resultModel, err := getResultModelByIDAndUserID(resultID, userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if resultModel == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	shareLinkModel := model.ShareLink{
+		Token:     token,
+		ResultID:  resultModel.ID,
+		CreatedAt: now,
+		ExpiresAt: postShareRequest.ExpiresAt,
+	}
+	if err := model.DB.Create(&shareLinkModel).Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapShareLink(shareLinkModel))
+}
+
+func GetShares(c *gin.Context) {
+	resultID := c.Param("resultID")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	resultModel, err := getResultModelByIDAndUserID(resultID, userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if resultModel == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var shareLinksModel []model.ShareLink
+	if err := model.DB.
+		Where("result_id = ?", resultModel.ID).
+		Order("created_at DESC").
+		Find(&shareLinksModel).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	shareLinks := mapShareLinks(shareLinksModel)
+	c.JSON(http.StatusOK, &shareLinks)
+}
+
+func DeleteShare(c *gin.Context) {
+	resultID := c.Param("resultID")
+	token := c.Param("token")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	resultModel, err := getResultModelByIDAndUserID(resultID, userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if resultModel == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	tx := model.DB.
+		Model(&model.ShareLink{}).
+		Where("token = ? AND result_id = ? AND revoked_at IS NULL", token, resultModel.ID).
+		Update("revoked_at", time.Now())
+	if err := tx.Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetSharedGacha(c *gin.Context) {
+	token := c.Param("token")
+	shareLinkModel, err := getActiveShareLinkModel(token, time.Now())
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if shareLinkModel == nil || shareLinkModel.Result == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	resultResponse, err := mapResultResponse(shareLinkModel.Result, c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	resultResponse.UserID = ""
+	c.JSON(http.StatusOK, resultResponse)
+}
+
+func getActiveShareLinkModel(token string, now time.Time) (*model.ShareLink, error) {
+	var shareLinkModel model.ShareLink
+	if err := model.DB.
+		Where("token = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", token, now).
+		Preload("Result.GameTitle.Translations").
+		First(&shareLinkModel).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &shareLinkModel, nil
+}
+
+func generateShareToken() (string, error) {
+	b := make([]byte, ShareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func mapShareLink(shareLinkModel model.ShareLink) *ShareLink {
+	return &ShareLink{
+		Token:     shareLinkModel.Token,
+		ResultID:  shareLinkModel.ResultID,
+		CreatedAt: shareLinkModel.CreatedAt,
+		ExpiresAt: shareLinkModel.ExpiresAt,
+		Revoked:   shareLinkModel.RevokedAt != nil,
+	}
+}
+
+func mapShareLinks(shareLinksModel []model.ShareLink) []ShareLink {
+	shareLinks := make([]ShareLink, 0)
+	for i := 0; i < len(shareLinksModel); i++ {
+		shareLink := mapShareLink(shareLinksModel[i])
+		shareLinks = append(shareLinks, *shareLink)
+	}
+	return shareLinks
+}
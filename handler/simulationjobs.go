@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"gacha-simulator/gacha"
+	"gacha-simulator/job"
+	"gacha-simulator/model"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const DefaultMaxSimulationRuns = 10000
+
+type SimulationJobRequest struct {
+	GachaRequest
+	Runs int `json:"runs"`
+}
+
+type SimulationJob struct {
+	ID         uint                     `json:"id"`
+	Status     string                   `json:"status"`
+	Runs       int                      `json:"runs"`
+	Progress   int                      `json:"progress"`
+	Summary    *gacha.SimulationSummary `json:"summary"`
+	Error      string                   `json:"error"`
+	CreatedAt  time.Time                `json:"createdAt"`
+	StartedAt  *time.Time               `json:"startedAt"`
+	FinishedAt *time.Time               `json:"finishedAt"`
+}
+
+func PostSimulationJob(c *gin.Context) {
+	var simulationJobRequest SimulationJobRequest
+	c.Bind(&simulationJobRequest)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if simulationJobRequest.Runs <= 0 || simulationJobRequest.Runs > getMaxSimulationRuns() {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	request, ok, err := resolveGachaRequest(simulationJobRequest.GachaRequest)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	simulationJobModel := model.SimulationJob{
+		UserID:      userID,
+		GameTitleID: simulationJobRequest.GameTitle.ID,
+		Request:     datatypes.JSON(requestJSON),
+		Runs:        simulationJobRequest.Runs,
+		Status:      job.SimulationJobStatusQueued,
+		CreatedAt:   time.Now(),
+	}
+	if err := model.DB.Create(&simulationJobModel).Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	simulationJob, err := mapSimulationJob(simulationJobModel)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusAccepted, simulationJob)
+}
+
+func GetSimulationJob(c *gin.Context) {
+	simulationJobID := c.Param("simulationJobID")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	simulationJobModel, err := getSimulationJobModelByIDAndUserID(simulationJobID, userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if simulationJobModel == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	simulationJob, err := mapSimulationJob(*simulationJobModel)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, simulationJob)
+}
+
+func DeleteSimulationJob(c *gin.Context) {
+	simulationJobID := c.Param("simulationJobID")
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	simulationJobModel, err := getSimulationJobModelByIDAndUserID(simulationJobID, userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if simulationJobModel == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	tx := model.DB.
+		Model(&model.SimulationJob{}).
+		Where("id = ? AND status = ?", simulationJobModel.ID, job.SimulationJobStatusQueued).
+		Updates(map[string]interface{}{
+			"status":      job.SimulationJobStatusCancelled,
+			"finished_at": time.Now(),
+		})
+	if err := tx.Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if tx.RowsAffected > 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	tx = model.DB.
+		Model(&model.SimulationJob{}).
+		Where("id = ? AND status = ?", simulationJobModel.ID, job.SimulationJobStatusRunning).
+		Update("cancel_requested", true)
+	if err := tx.Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func getSimulationJobModelByIDAndUserID(simulationJobID, userID string) (*model.SimulationJob, error) {
+	var simulationJobModel model.SimulationJob
+	if err := model.DB.
+		Where("id = ? AND user_id = ?", simulationJobID, userID).
+		First(&simulationJobModel).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &simulationJobModel, nil
+}
+
+func getMaxSimulationRuns() int {
+	maxSimulationRunsStr := os.Getenv("SIMULATION_MAX_RUNS")
+	if maxSimulationRunsStr == "" {
+		return DefaultMaxSimulationRuns
+	}
+	maxSimulationRuns, err := strconv.Atoi(maxSimulationRunsStr)
+	if err != nil {
+		panic(err)
+	}
+	return maxSimulationRuns
+}
+
+func mapSimulationJob(simulationJobModel model.SimulationJob) (*SimulationJob, error) {
+	var summary *gacha.SimulationSummary
+	if len(simulationJobModel.Summary) > 0 {
+		if err := json.Unmarshal(simulationJobModel.Summary, &summary); err != nil {
+			return nil, err
+		}
+	}
+	return &SimulationJob{
+		ID:         simulationJobModel.ID,
+		Status:     simulationJobModel.Status,
+		Runs:       simulationJobModel.Runs,
+		Progress:   simulationJobModel.Progress,
+		Summary:    summary,
+		Error:      simulationJobModel.Error,
+		CreatedAt:  simulationJobModel.CreatedAt,
+		StartedAt:  simulationJobModel.StartedAt,
+		FinishedAt: simulationJobModel.FinishedAt,
+	}, nil
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"gacha-simulator/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const MostPulledItemCount = 5
+
+type GameTitleStats struct {
+	GameTitle           GameTitle        `json:"gameTitle"`
+	Sessions            int64            `json:"sessions"`
+	TotalPulls          int64            `json:"totalPulls"`
+	TotalMoneySpent     float64          `json:"totalMoneySpent"`
+	GoalsAchieved       int64            `json:"goalsAchieved"`
+	GoalSuccessRate     float64          `json:"goalSuccessRate"`
+	TopTierPulls        int64            `json:"topTierPulls"`
+	TopTierRate         float64          `json:"topTierRate"`
+	ExpectedTopTierRate float64          `json:"expectedTopTierRate"`
+	MostPulledItems     []ItemWithNumber `json:"mostPulledItems"`
+}
+
+type gameTitleStatsRow struct {
+	GameTitleID   uint
+	Sessions      int64
+	Pulls         int64
+	MoneySpent    float64
+	GoalsAchieved int64
+}
+
+type topTierPullsRow struct {
+	GameTitleID  uint
+	TopTierPulls int64
+}
+
+type mostPulledItemRow struct {
+	GameTitleID uint
+	ItemID      uint
+	Pulls       int64
+}
+
+func GetMyStats(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	statsRows, err := getGameTitleStatsRows(userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	topTierPullsRows, err := getTopTierPullsRows(userID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	mostPulledItemRows, err := getMostPulledItemRows(userID, MostPulledItemCount)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	gameTitleIDs := make([]uint, 0)
+	for _, statsRow := range statsRows {
+		gameTitleIDs = append(gameTitleIDs, statsRow.GameTitleID)
+	}
+	var gameTitlesModel []model.GameTitle
+	if err := model.DB.
+		Preload("Translations").
+		Where("id IN ?", gameTitleIDs).
+		Order("display_order").
+		Find(&gameTitlesModel).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	var tiersModel []model.Tier
+	if err := model.DB.
+		Where("game_title_id IN ?", gameTitleIDs).
+		Find(&tiersModel).
+		Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	itemIDs := make([]uint, 0)
+	for _, mostPulledItemRow := range mostPulledItemRows {
+		itemIDs = append(itemIDs, mostPulledItemRow.ItemID)
+	}
+	itemsModel, err := getItemsModelByIDs(itemIDs)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	stats := mapGameTitleStats(
+		gameTitlesModel,
+		statsRows,
+		topTierPullsRows,
+		mostPulledItemRows,
+		tiersModel,
+		itemsModel,
+		c,
+	)
+	c.JSON(http.StatusOK, &stats)
+}
+
+func getGameTitleStatsRows(userID string) ([]gameTitleStatsRow, error) {
+	var statsRows []gameTitleStatsRow
+	if err := model.DB.Raw(`
+		SELECT results.game_title_id,
+			COUNT(*) AS sessions,
+			COALESCE(SUM(jsonb_array_length(results.item_ids)), 0) AS pulls,
+			COALESCE(SUM(results.money_spent), 0) AS money_spent,
+			COUNT(*) FILTER (WHERE results.goals_achieved) AS goals_achieved
+		FROM results
+		JOIN game_titles ON game_titles.id = results.game_title_id AND game_titles.deleted_at IS NULL
+		WHERE results.user_id = ?
+		GROUP BY results.game_title_id`, userID).
+		Scan(&statsRows).
+		Error; err != nil {
+		return nil, err
+	}
+	return statsRows, nil
+}
+
+func getTopTierPullsRows(userID string) ([]topTierPullsRow, error) {
+	var topTierPullsRows []topTierPullsRow
+	if err := model.DB.Raw(`
+		SELECT results.game_title_id, COUNT(*) AS top_tier_pulls
+		FROM results
+		CROSS JOIN LATERAL jsonb_array_elements_text(results.item_ids) AS pulled(item_id)
+		JOIN items ON items.id = pulled.item_id::bigint
+		JOIN tiers ON tiers.id = items.tier_id
+		WHERE results.user_id = ?
+			AND tiers.ratio = (
+				SELECT MIN(top_tiers.ratio) FROM tiers AS top_tiers
+				WHERE top_tiers.game_title_id = results.game_title_id AND top_tiers.ratio > 0
+			)
+		GROUP BY results.game_title_id`, userID).
+		Scan(&topTierPullsRows).
+		Error; err != nil {
+		return nil, err
+	}
+	return topTierPullsRows, nil
+}
+
+func getMostPulledItemRows(userID string, limit int) ([]mostPulledItemRow, error) {
+	var mostPulledItemRows []mostPulledItemRow
+	if err := model.DB.Raw(`
+		SELECT ranked.game_title_id, ranked.item_id, ranked.pulls
+		FROM (
+			SELECT results.game_title_id,
+				pulled.item_id::bigint AS item_id,
+				COUNT(*) AS pulls,
+				ROW_NUMBER() OVER (
+					PARTITION BY results.game_title_id
+					ORDER BY COUNT(*) DESC, pulled.item_id::bigint
+				) AS rank
+			FROM results
+			CROSS JOIN LATERAL jsonb_array_elements_text(results.item_ids) AS pulled(item_id)
+			WHERE results.user_id = ?
+			GROUP BY results.game_title_id, pulled.item_id
+		) AS ranked
+		WHERE ranked.rank <= ?
+		ORDER BY ranked.game_title_id, ranked.rank`, userID, limit).
+		Scan(&mostPulledItemRows).
+		Error; err != nil {
+		return nil, err
+	}
+	return mostPulledItemRows, nil
+}
+
+func getExpectedTopTierRate(tiersModel []model.Tier, gameTitleID uint) float64 {
+	minRatio := 0
+	ratioSum := 0
+	for _, tierModel := range tiersModel {
+		if tierModel.GameTitleID != gameTitleID || tierModel.Ratio <= 0 {
+			continue
+		}
+		ratioSum += tierModel.Ratio
+		if minRatio == 0 || tierModel.Ratio < minRatio {
+			minRatio = tierModel.Ratio
+		}
+	}
+	if ratioSum == 0 {
+		return 0
+	}
+	topRatioSum := 0
+	for _, tierModel := range tiersModel {
+		if tierModel.GameTitleID == gameTitleID && tierModel.Ratio == minRatio {
+			topRatioSum += tierModel.Ratio
+		}
+	}
+	return float64(topRatioSum) / float64(ratioSum)
+}
+
+func mapGameTitleStats(
+	gameTitlesModel []model.GameTitle,
+	statsRows []gameTitleStatsRow,
+	topTierPullsRows []topTierPullsRow,
+	mostPulledItemRows []mostPulledItemRow,
+	tiersModel []model.Tier,
+	itemsModel []model.Item,
+	c *gin.Context,
+) []GameTitleStats {
+	statsRowMap := make(map[uint]gameTitleStatsRow)
+	for _, statsRow := range statsRows {
+		statsRowMap[statsRow.GameTitleID] = statsRow
+	}
+	topTierPullsMap := make(map[uint]int64)
+	for _, topTierPullsRow := range topTierPullsRows {
+		topTierPullsMap[topTierPullsRow.GameTitleID] = topTierPullsRow.TopTierPulls
+	}
+	itemModelMap := make(map[uint]model.Item)
+	for _, itemModel := range itemsModel {
+		itemModelMap[itemModel.ID] = itemModel
+	}
+	stats := make([]GameTitleStats, 0)
+	for _, gameTitleModel := range gameTitlesModel {
+		statsRow := statsRowMap[gameTitleModel.ID]
+		gameTitleStats := GameTitleStats{
+			GameTitle:           *mapGameTitle(gameTitleModel, c),
+			Sessions:            statsRow.Sessions,
+			TotalPulls:          statsRow.Pulls,
+			TotalMoneySpent:     statsRow.MoneySpent,
+			GoalsAchieved:       statsRow.GoalsAchieved,
+			TopTierPulls:        topTierPullsMap[gameTitleModel.ID],
+			ExpectedTopTierRate: getExpectedTopTierRate(tiersModel, gameTitleModel.ID),
+			MostPulledItems:     make([]ItemWithNumber, 0),
+		}
+		if statsRow.Sessions > 0 {
+			gameTitleStats.GoalSuccessRate = float64(statsRow.GoalsAchieved) / float64(statsRow.Sessions)
+		}
+		if statsRow.Pulls > 0 {
+			gameTitleStats.TopTierRate = float64(gameTitleStats.TopTierPulls) / float64(statsRow.Pulls)
+		}
+		for _, mostPulledItemRow := range mostPulledItemRows {
+			if mostPulledItemRow.GameTitleID != gameTitleModel.ID {
+				continue
+			}
+			if itemModel, ok := itemModelMap[mostPulledItemRow.ItemID]; ok {
+				gameTitleStats.MostPulledItems = append(gameTitleStats.MostPulledItems, ItemWithNumber{
+					Item:   *mapItem(itemModel, c),
+					Number: uint(mostPulledItemRow.Pulls),
+				})
+			}
+		}
+		stats = append(stats, gameTitleStats)
+	}
+	return stats
+}
@@ -0,0 +1,145 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	dayStar  bool
+	weekStar bool
+}
+
+type cronField struct {
+	min int
+	max int
+}
+
+var cronFields = []cronField{
+	{min: 0, max: 59},
+	{min: 0, max: 23},
+	{min: 1, max: 31},
+	{min: 1, max: 12},
+	{min: 0, max: 7},
+}
+
+const scheduleSearchYears = 5
+
+func ParseSchedule(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields in schedule %q", len(cronFields), spec)
+	}
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		bits[i] = b
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+		bits[4] &^= 1 << 7
+	}
+	return &Schedule{
+		minutes:  bits[0],
+		hours:    bits[1],
+		days:     bits[2],
+		months:   bits[3],
+		weekdays: bits[4],
+		dayStar:  strings.HasPrefix(fields[2], "*"),
+		weekStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart := part
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = s
+			rangePart = part[:i]
+		}
+		start, end := bounds.min, bounds.max
+		if rangePart != "*" {
+			if i := strings.Index(rangePart, "-"); i >= 0 {
+				s, err := strconv.Atoi(rangePart[:i])
+				if err != nil {
+					return 0, fmt.Errorf("invalid range in %q", part)
+				}
+				e, err := strconv.Atoi(rangePart[i+1:])
+				if err != nil {
+					return 0, fmt.Errorf("invalid range in %q", part)
+				}
+				start, end = s, e
+			} else {
+				v, err := strconv.Atoi(rangePart)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+				start = v
+				if step == 1 {
+					end = v
+				}
+			}
+		}
+		if start < bounds.min || end > bounds.max || start > end {
+			return 0, fmt.Errorf("out of range value in %q", part)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	if bits == 0 {
+		return 0, errors.New("empty field")
+	}
+	return bits, nil
+}
+
+func (schedule *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(scheduleSearchYears, 0, 0)
+	for t.Before(limit) {
+		if schedule.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !schedule.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if schedule.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if schedule.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (schedule *Schedule) dayMatches(t time.Time) bool {
+	dayMatch := schedule.days&(1<<uint(t.Day())) != 0
+	weekdayMatch := schedule.weekdays&(1<<uint(t.Weekday())) != 0
+	if schedule.dayStar || schedule.weekStar {
+		return dayMatch && weekdayMatch
+	}
+	return dayMatch || weekdayMatch
+}
@@ -0,0 +1,38 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 15, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"0 * * * *", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2024, time.January, 31, 10, 20, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, time.February, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 7", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		schedule, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", tc.spec, err)
+			continue
+		}
+		if next := schedule.Next(from); !next.Equal(tc.next) {
+			t.Errorf("Unexpected next run for %q: %s", tc.spec, next)
+		}
+	}
+}
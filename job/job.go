@@ -1,39 +1,146 @@
 package job
 
 import (
+	"context"
 	"fmt"
 	"gacha-simulator/model"
 	"os"
+	"strconv"
 	"time"
 )
 
-func InitJobs() {
-	ticker := time.NewTicker(time.Hour * time.Duration(1))
-	go cleanObsoleteResults(ticker)
+const DefaultGameTitleRetentionHours = 24 * 30
+const DefaultResultRetentionHours = 24 * 7
+const DefaultResultCleanupBatchSize = 1000
+const DefaultGachaSessionTTLHours = 24
+
+const (
+	JobCleanObsoleteResults       = "clean-obsolete-results"
+	JobPurgeDeletedGameTitles     = "purge-deleted-game-titles"
+	JobRequeueStaleSimulationJobs = "requeue-stale-simulation-jobs"
+	JobCleanStaleGachaSessions    = "clean-stale-gacha-sessions"
+)
+
+const (
+	CleanObsoleteResultsSchedule       = "0 * * * *"
+	PurgeDeletedGameTitlesSchedule     = "30 * * * *"
+	RequeueStaleSimulationJobsSchedule = "* * * * *"
+	CleanStaleGachaSessionsSchedule    = "15 * * * *"
+)
+
+var scheduler = NewScheduler()
+
+func InitJobs(ctx context.Context) {
+	retentionHours := getResultRetentionHours()
+	batchSize := getResultCleanupBatchSize()
+	gameTitleRetention := getGameTitleRetention()
+	sessionTTL := getGachaSessionTTL()
+	simulationMaxAttempts := getSimulationMaxAttempts()
+	scheduler.Register(JobCleanObsoleteResults, CleanObsoleteResultsSchedule, func(ctx context.Context) error {
+		return cleanObsoleteResults(ctx, retentionHours, batchSize)
+	})
+	scheduler.Register(JobPurgeDeletedGameTitles, PurgeDeletedGameTitlesSchedule, func(ctx context.Context) error {
+		return purgeDeletedGameTitles(ctx, gameTitleRetention)
+	})
+	scheduler.Register(JobRequeueStaleSimulationJobs, RequeueStaleSimulationJobsSchedule, func(ctx context.Context) error {
+		return requeueStaleSimulationJobs(ctx, simulationMaxAttempts)
+	})
+	scheduler.Register(JobCleanStaleGachaSessions, CleanStaleGachaSessionsSchedule, func(ctx context.Context) error {
+		return cleanStaleGachaSessions(ctx, sessionTTL)
+	})
+	scheduler.Start(ctx)
+}
+
+func Wait() {
+	scheduler.Wait()
 }
 
-func cleanObsoleteResults(ticker *time.Ticker) {
-	for range ticker.C {
-		oneWeekAgo := time.Now().Add(-(time.Hour * time.Duration(24*7)))
-		var count int64
-		if err := model.DB.
+func Jobs() []JobInfo {
+	return scheduler.Jobs()
+}
+
+func cleanObsoleteResults(ctx context.Context, retentionHours, batchSize int) error {
+	now := time.Now()
+	for {
+		obsoleteResultIDs := model.DB.
 			Model(&model.Result{}).
-			Where("time <= ?", oneWeekAgo).
-			Count(&count).
-			Error; err != nil {
-			errorf("[ERROR]:%s\n", err)
+			Select("results.id").
+			Joins("JOIN game_titles on game_titles.id=results.game_title_id").
+			Where("results.pinned = ?", false).
+			Where("results.time <= ?::timestamptz - make_interval(hours => COALESCE(game_titles.result_retention_hours, ?))", now, retentionHours).
+			Limit(batchSize)
+		tx := model.DB.
+			WithContext(ctx).
+			Where("id IN (?)", obsoleteResultIDs).
+			Delete(&model.Result{})
+		if err := tx.Error; err != nil {
+			return err
 		}
-		if count > 0 {
-			if err := model.DB.
-				Where("time <= ?", oneWeekAgo).
-				Delete(&model.Result{}).
-				Error; err != nil {
-				errorf("[ERROR]:%s\n", err)
-			}
+		if tx.RowsAffected < int64(batchSize) {
+			return nil
 		}
 	}
 }
 
+func purgeDeletedGameTitles(ctx context.Context, retention time.Duration) error {
+	threshold := time.Now().Add(-retention)
+	return model.DB.
+		WithContext(ctx).
+		Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", threshold).
+		Delete(&model.GameTitle{}).
+		Error
+}
+
+func cleanStaleGachaSessions(ctx context.Context, ttl time.Duration) error {
+	threshold := time.Now().Add(-ttl)
+	return model.DB.
+		WithContext(ctx).
+		Where("updated_at <= ?", threshold).
+		Delete(&model.GachaSession{}).
+		Error
+}
+
+func getGameTitleRetention() time.Duration {
+	return time.Hour * time.Duration(getEnvInt("GAME_TITLE_RETENTION_HOURS", DefaultGameTitleRetentionHours))
+}
+
+func getGachaSessionTTL() time.Duration {
+	return time.Hour * time.Duration(getEnvInt("GACHA_SESSION_TTL_HOURS", DefaultGachaSessionTTLHours))
+}
+
+func getResultRetentionHours() int {
+	return getEnvInt("RESULT_RETENTION_HOURS", DefaultResultRetentionHours)
+}
+
+func getResultCleanupBatchSize() int {
+	batchSize := getEnvInt("RESULT_CLEANUP_BATCH_SIZE", DefaultResultCleanupBatchSize)
+	if batchSize <= 0 {
+		panic("non-positive RESULT_CLEANUP_BATCH_SIZE")
+	}
+	return batchSize
+}
+
+func getSimulationMaxAttempts() int {
+	maxAttempts := getEnvInt("SIMULATION_MAX_ATTEMPTS", DefaultSimulationMaxAttempts)
+	if maxAttempts <= 0 {
+		panic("non-positive SIMULATION_MAX_ATTEMPTS")
+	}
+	return maxAttempts
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
 func errorf(format string, args ...interface{}) {
 	buf := fmt.Sprintf(format, args...)
 	os.Stderr.Write([]byte(buf))
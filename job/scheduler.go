@@ -0,0 +1,167 @@
+package job
+
+import (
+	"context"
+	"gacha-simulator/model"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const AdvisoryLockPrefix = "gacha-simulator:job:"
+
+const (
+	JobRunStatusRunning   = "running"
+	JobRunStatusSuccess   = "success"
+	JobRunStatusFailure   = "failure"
+	JobRunStatusCancelled = "cancelled"
+)
+
+type Job struct {
+	Name     string
+	Spec     string
+	Schedule *Schedule
+	Run      func(ctx context.Context) error
+}
+
+type JobInfo struct {
+	Name    string
+	Spec    string
+	NextRun time.Time
+}
+
+type Scheduler struct {
+	jobs     []*Job
+	nextRuns map[string]time.Time
+	mutex    sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs:     make([]*Job, 0),
+		nextRuns: make(map[string]time.Time),
+	}
+}
+
+func (scheduler *Scheduler) Register(name, spec string, run func(ctx context.Context) error) {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		panic(err)
+	}
+	scheduler.jobs = append(scheduler.jobs, &Job{
+		Name:     name,
+		Spec:     spec,
+		Schedule: schedule,
+		Run:      run,
+	})
+}
+
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	for _, job := range scheduler.jobs {
+		scheduler.wg.Add(1)
+		go scheduler.loop(ctx, job)
+	}
+}
+
+func (scheduler *Scheduler) Wait() {
+	scheduler.wg.Wait()
+}
+
+func (scheduler *Scheduler) Jobs() []JobInfo {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	jobInfos := make([]JobInfo, 0)
+	for _, job := range scheduler.jobs {
+		jobInfos = append(jobInfos, JobInfo{
+			Name:    job.Name,
+			Spec:    job.Spec,
+			NextRun: scheduler.nextRuns[job.Name],
+		})
+	}
+	return jobInfos
+}
+
+func (scheduler *Scheduler) loop(ctx context.Context, job *Job) {
+	defer scheduler.wg.Done()
+	for {
+		next := job.Schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		scheduler.mutex.Lock()
+		scheduler.nextRuns[job.Name] = next
+		scheduler.mutex.Unlock()
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			scheduler.run(ctx, job, next)
+		}
+	}
+}
+
+func (scheduler *Scheduler) run(ctx context.Context, job *Job, scheduledAt time.Time) {
+	tx := model.DB.WithContext(ctx).Begin()
+	if err := tx.Error; err != nil {
+		errorf("[ERROR]:%s\n", err)
+		return
+	}
+	defer tx.Rollback()
+	var acquired bool
+	if err := tx.
+		Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", AdvisoryLockPrefix+job.Name).
+		Scan(&acquired).
+		Error; err != nil {
+		errorf("[ERROR]:%s\n", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	jobRunModel := model.JobRun{
+		Name:        job.Name,
+		ScheduledAt: &scheduledAt,
+		StartedAt:   time.Now(),
+		Status:      JobRunStatusRunning,
+	}
+	created := model.DB.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&jobRunModel)
+	if err := created.Error; err != nil {
+		errorf("[ERROR]:%s\n", err)
+		return
+	}
+	if created.RowsAffected == 0 {
+		return
+	}
+
+	err := job.Run(ctx)
+
+	finishedAt := time.Now()
+	status := JobRunStatusSuccess
+	errorMessage := ""
+	if err != nil {
+		errorf("[ERROR]:%s: %s\n", job.Name, err)
+		status = JobRunStatusFailure
+		if ctx.Err() != nil {
+			status = JobRunStatusCancelled
+		}
+		errorMessage = err.Error()
+	}
+	if err := model.DB.
+		Model(&jobRunModel).
+		Updates(map[string]interface{}{
+			"finished_at": finishedAt,
+			"duration_ms": finishedAt.Sub(jobRunModel.StartedAt).Milliseconds(),
+			"status":      status,
+			"error":       errorMessage,
+		}).
+		Error; err != nil {
+		errorf("[ERROR]:%s\n", err)
+	}
+}
@@ -0,0 +1,236 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"gacha-simulator/gacha"
+	"gacha-simulator/model"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const DefaultSimulationWorkers = 2
+const DefaultSimulationMaxAttempts = 3
+
+const SimulationMaxAttemptsError = "max attempts exceeded"
+
+const (
+	SimulationPollInterval      = 2 * time.Second
+	SimulationHeartbeatInterval = 5 * time.Second
+	SimulationStaleTimeout      = time.Minute
+)
+
+const (
+	SimulationJobStatusQueued    = "queued"
+	SimulationJobStatusRunning   = "running"
+	SimulationJobStatusSucceeded = "succeeded"
+	SimulationJobStatusFailed    = "failed"
+	SimulationJobStatusCancelled = "cancelled"
+)
+
+func InitSimulationWorkers(ctx context.Context, bindLoaders func(request *gacha.Request)) {
+	workers := getEnvInt("SIMULATION_WORKERS", DefaultSimulationWorkers)
+	for i := 0; i < workers; i++ {
+		scheduler.wg.Add(1)
+		go simulationWorker(ctx, bindLoaders)
+	}
+}
+
+func simulationWorker(ctx context.Context, bindLoaders func(request *gacha.Request)) {
+	defer scheduler.wg.Done()
+	for {
+		simulationJobModel, err := claimSimulationJob()
+		if err != nil {
+			errorf("[ERROR]:%s\n", err)
+		}
+		if simulationJobModel == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(SimulationPollInterval):
+			}
+			continue
+		}
+		runSimulationJob(ctx, *simulationJobModel, bindLoaders)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func claimSimulationJob() (*model.SimulationJob, error) {
+	var simulationJobModel model.SimulationJob
+	claimed := false
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", SimulationJobStatusQueued).
+			Order("id").
+			Limit(1).
+			Find(&simulationJobModel)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		now := time.Now()
+		claimed = true
+		return tx.
+			Model(&simulationJobModel).
+			Updates(map[string]interface{}{
+				"status":       SimulationJobStatusRunning,
+				"progress":     0,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"started_at":   now,
+				"heartbeat_at": now,
+			}).
+			Error
+	}); err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, nil
+	}
+	return &simulationJobModel, nil
+}
+
+func runSimulationJob(ctx context.Context, simulationJobModel model.SimulationJob, bindLoaders func(request *gacha.Request)) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var request gacha.Request
+	if err := json.Unmarshal(simulationJobModel.Request, &request); err != nil {
+		finishSimulationJob(simulationJobModel.ID, SimulationJobStatusFailed, 0, nil, err)
+		return
+	}
+	bindLoaders(&request)
+
+	var progress int64
+	done := make(chan struct{})
+	go heartbeatSimulationJob(simulationJobModel.ID, &progress, cancel, done)
+	summary, err := gacha.Simulate(jobCtx, request, simulationJobModel.Runs, func(completed int) {
+		atomic.StoreInt64(&progress, int64(completed))
+	})
+	close(done)
+
+	switch {
+	case err == nil:
+		finishSimulationJob(simulationJobModel.ID, SimulationJobStatusSucceeded, simulationJobModel.Runs, &summary, nil)
+	case ctx.Err() != nil:
+		requeueSimulationJob(simulationJobModel.ID)
+	case jobCtx.Err() != nil:
+		finishSimulationJob(simulationJobModel.ID, SimulationJobStatusCancelled, int(atomic.LoadInt64(&progress)), nil, nil)
+	default:
+		finishSimulationJob(simulationJobModel.ID, SimulationJobStatusFailed, int(atomic.LoadInt64(&progress)), nil, err)
+	}
+}
+
+func heartbeatSimulationJob(simulationJobID uint, progress *int64, cancel context.CancelFunc, done chan struct{}) {
+	ticker := time.NewTicker(SimulationHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if err := model.DB.
+			Model(&model.SimulationJob{}).
+			Where("id = ? AND status = ?", simulationJobID, SimulationJobStatusRunning).
+			Updates(map[string]interface{}{
+				"heartbeat_at": time.Now(),
+				"progress":     atomic.LoadInt64(progress),
+			}).
+			Error; err != nil {
+			errorf("[ERROR]:%s\n", err)
+			continue
+		}
+		var cancelRequested bool
+		if err := model.DB.
+			Model(&model.SimulationJob{}).
+			Select("cancel_requested").
+			Where("id = ?", simulationJobID).
+			Scan(&cancelRequested).
+			Error; err != nil {
+			errorf("[ERROR]:%s\n", err)
+			continue
+		}
+		if cancelRequested {
+			cancel()
+		}
+	}
+}
+
+func finishSimulationJob(simulationJobID uint, status string, progress int, summary *gacha.SimulationSummary, jobErr error) {
+	updates := map[string]interface{}{
+		"status":      status,
+		"progress":    progress,
+		"finished_at": time.Now(),
+	}
+	if summary != nil {
+		summaryJSON, err := json.Marshal(summary)
+		if err != nil {
+			errorf("[ERROR]:%s\n", err)
+			return
+		}
+		updates["summary"] = datatypes.JSON(summaryJSON)
+	}
+	if jobErr != nil {
+		updates["error"] = jobErr.Error()
+	}
+	if err := model.DB.
+		Model(&model.SimulationJob{}).
+		Where("id = ? AND status = ?", simulationJobID, SimulationJobStatusRunning).
+		Updates(updates).
+		Error; err != nil {
+		errorf("[ERROR]:%s\n", err)
+	}
+}
+
+func requeueSimulationJob(simulationJobID uint) {
+	if err := model.DB.
+		Model(&model.SimulationJob{}).
+		Where("id = ? AND status = ?", simulationJobID, SimulationJobStatusRunning).
+		Updates(map[string]interface{}{
+			"status":   SimulationJobStatusQueued,
+			"progress": 0,
+			"attempts": gorm.Expr("attempts - 1"),
+		}).
+		Error; err != nil {
+		errorf("[ERROR]:%s\n", err)
+	}
+}
+
+func requeueStaleSimulationJobs(ctx context.Context, maxAttempts int) error {
+	now := time.Now()
+	return model.DB.
+		WithContext(ctx).
+		Model(&model.SimulationJob{}).
+		Where("status = ? AND heartbeat_at < ?", SimulationJobStatusRunning, now.Add(-SimulationStaleTimeout)).
+		Updates(map[string]interface{}{
+			"status": gorm.Expr(
+				"CASE WHEN cancel_requested THEN ? WHEN attempts >= ? THEN ? ELSE ? END",
+				SimulationJobStatusCancelled,
+				maxAttempts,
+				SimulationJobStatusFailed,
+				SimulationJobStatusQueued,
+			),
+			"error": gorm.Expr(
+				"CASE WHEN NOT cancel_requested AND attempts >= ? THEN ? ELSE error END",
+				maxAttempts,
+				SimulationMaxAttemptsError,
+			),
+			"finished_at": gorm.Expr(
+				"CASE WHEN NOT cancel_requested AND attempts >= ? THEN ? ELSE finished_at END",
+				maxAttempts,
+				now,
+			),
+			"progress": 0,
+		}).
+		Error
+}
@@ -7,6 +7,9 @@ import (
 	"gacha-simulator/model"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-oauth2/oauth2/errors"
@@ -19,12 +22,15 @@ import (
 	oauth2gorm "src.techknowlogick.com/oauth2-gorm"
 )
 
+const ShutdownTimeout = 10 * time.Second
+
 func main() {
 	loadEnv()
 
 	dsn := getDSN()
 
 	model.SetupDB(dsn)
+	handler.InitLimits()
 
 	manager := manage.NewDefaultManager()
 
@@ -83,7 +89,9 @@ func main() {
 		return uuid.String(), err
 	})
 
-	job.InitJobs()
+	jobCtx, stopJobs := context.WithCancel(ctx)
+	job.InitJobs(jobCtx)
+	job.InitSimulationWorkers(jobCtx, handler.BindGachaRequestLoaders)
 
 	ginEngine := gin.Default()
 	apiGroup := ginEngine.Group("/api")
@@ -119,20 +127,52 @@ func main() {
 			gameTitlesGroup.GET("", handler.GetGameTitles)
 			gameTitlesGroup.GET("/:gameTitleSlug", handler.GetGameTitle)
 			gameTitlesGroup.GET("/:gameTitleSlug/presets", handler.GetPresets)
+			gameTitlesGroup.GET("/:gameTitleSlug/banners", handler.GetBanners)
+			gameTitlesGroup.GET("/:gameTitleSlug/banners/:bannerID", handler.GetBanner)
 			gameTitlesGroup.GET("/:gameTitleSlug/tiers", handler.GetTiers)
 			gameTitlesGroup.GET("/:gameTitleSlug/items", handler.GetItems)
 			gameTitlesGroup.GET("/:gameTitleSlug/pricings", handler.GetPricings)
 			gameTitlesGroup.GET("/:gameTitleSlug/policies", handler.GetPolicies)
 			gameTitlesGroup.GET("/:gameTitleSlug/plans", handler.GetPlans)
 			gameTitlesGroup.GET("/:gameTitleSlug/gachas", handler.GetGachas)
+			gameTitlesGroup.GET("/:gameTitleSlug/gachas/export", handler.GetGachasExport)
+			gameTitlesGroup.GET("/:gameTitleSlug/public-gachas", handler.GetPublicGachas)
 		}
 		gachasGroup := apiGroup.Group("/gachas")
 		{
 			gachasGroup.Use(validateBearerToken)
 			gachasGroup.POST("", handler.PostGachas)
+			gachasGroup.POST("/stream", handler.PostGachasStream)
+			gachasGroup.POST("/optimize-budget", handler.PostBudgetOptimization)
+			gachasGroup.POST("/compare", handler.PostPlanComparison)
 			gachasGroup.GET("/:resultID", handler.GetGacha)
 			gachasGroup.PATCH("/:resultID", handler.PatchGacha)
 			gachasGroup.DELETE("/:resultID", handler.DeleteGacha)
+			gachasGroup.POST("/:resultID/shares", handler.PostShare)
+			gachasGroup.GET("/:resultID/shares", handler.GetShares)
+			gachasGroup.DELETE("/:resultID/shares/:token", handler.DeleteShare)
+		}
+		apiGroup.GET("/shared/:token", handler.GetSharedGacha)
+		simulationJobsGroup := apiGroup.Group("/simulation-jobs")
+		{
+			simulationJobsGroup.Use(validateBearerToken)
+			simulationJobsGroup.POST("", handler.PostSimulationJob)
+			simulationJobsGroup.GET("/:simulationJobID", handler.GetSimulationJob)
+			simulationJobsGroup.DELETE("/:simulationJobID", handler.DeleteSimulationJob)
+		}
+		sessionsGroup := apiGroup.Group("/sessions")
+		{
+			sessionsGroup.Use(validateBearerToken)
+			sessionsGroup.POST("", handler.PostGachaSession)
+			sessionsGroup.GET("/:sessionID", handler.GetGachaSession)
+			sessionsGroup.POST("/:sessionID/pull", handler.PostGachaSessionPull)
+			sessionsGroup.POST("/:sessionID/finalize", handler.PostGachaSessionFinalize)
+			sessionsGroup.DELETE("/:sessionID", handler.DeleteGachaSession)
+		}
+		meGroup := apiGroup.Group("/me")
+		{
+			meGroup.Use(validateBearerToken)
+			meGroup.GET("/stats", handler.GetMyStats)
 		}
 		adminGroup := apiGroup.Group("/admin")
 		{
@@ -148,13 +188,47 @@ func main() {
 					ctx.AbortWithStatus(http.StatusForbidden)
 					return
 				}
+				ctx.Set("access_token", ti)
 				ctx.Next()
 			})
+			adminGroup.Use(handler.AuditAdminMutations)
 			adminGroup.POST("game-titles-bulk", handler.PostGameTitlesBulk)
+			adminGroup.POST("game-titles-bulk-stream", handler.PostGameTitlesBulkStream)
+			adminGroup.POST("game-titles-bulk-csv", handler.PostGameTitlesBulkCSV)
 			adminGroup.DELETE("game-titles/:gameTitleSlug", handler.DeleteGameTitle)
+			adminGroup.POST("game-titles/:gameTitleSlug/restore", handler.RestoreGameTitle)
+			adminGroup.GET("audit", handler.GetAuditLogs)
+			adminGroup.GET("jobs", handler.GetJobs)
+		}
+	}
+
+	httpServer := &http.Server{
+		Addr:    getAddr(),
+		Handler: ginEngine,
+	}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			panic(err)
 		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, ShutdownTimeout)
+	defer cancel()
+	httpServer.Shutdown(shutdownCtx)
+	stopJobs()
+	job.Wait()
+}
+
+func getAddr() string {
+	port := os.Getenv("PORT")
+	if port == "" {
+		return ":8080"
 	}
-	ginEngine.Run()
+	return ":" + port
 }
 
 func getDSN() string {
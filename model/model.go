@@ -12,11 +12,13 @@ import (
 )
 
 type GameTitle struct {
-	ID           uint
-	Slug         string `gorm:"size:256;unique;index;notNull"`
-	ImageURL     string
-	DisplayOrder uint
-	Translations []GameTitleTranslation `gorm:"constraint:OnDelete:CASCADE;"`
+	ID                   uint
+	Slug                 string `gorm:"size:256;index;uniqueIndex:idx_game_titles_slug_active,where:deleted_at IS NULL;notNull"`
+	ImageURL             string
+	DisplayOrder         uint
+	ResultRetentionHours *int
+	Translations         []GameTitleTranslation `gorm:"constraint:OnDelete:CASCADE;"`
+	DeletedAt            gorm.DeletedAt         `gorm:"index"`
 }
 
 type GameTitleTranslation struct {
@@ -30,13 +32,14 @@ type GameTitleTranslation struct {
 }
 
 type Tier struct {
-	ID           uint
-	Ratio        int
-	Items        []Item     `gorm:"constraint:OnDelete:CASCADE;"`
-	GameTitle    *GameTitle `gorm:"constraint:OnDelete:CASCADE;"`
-	GameTitleID  uint
-	ImageURL     string
-	Translations []TierTranslation `gorm:"constraint:OnDelete:CASCADE;"`
+	ID              uint
+	Ratio           int
+	ConversionValue float64
+	Items           []Item     `gorm:"constraint:OnDelete:CASCADE;"`
+	GameTitle       *GameTitle `gorm:"constraint:OnDelete:CASCADE;"`
+	GameTitleID     uint
+	ImageURL        string
+	Translations    []TierTranslation `gorm:"constraint:OnDelete:CASCADE;"`
 }
 
 type TierTranslation struct {
@@ -51,6 +54,7 @@ type TierTranslation struct {
 type Item struct {
 	ID           uint
 	Ratio        int
+	ShopPrice    *float64
 	ImageURL     string
 	Tier         *Tier
 	TierID       uint
@@ -113,7 +117,10 @@ type Plan struct {
 	WantedItemsJSON      datatypes.JSON `gorm:"column:wanted_items"`
 	TierGoals            bool
 	WantedTiersJSON      datatypes.JSON `gorm:"column:wanted_tiers"`
-	GameTitle            *GameTitle     `gorm:"constraint:OnDelete:CASCADE;"`
+	GoalJSON             datatypes.JSON `gorm:"column:goal"`
+	StopStrategiesJSON   datatypes.JSON `gorm:"column:stop_strategies"`
+	UseShop              bool
+	GameTitle            *GameTitle `gorm:"constraint:OnDelete:CASCADE;"`
 	GameTitleID          uint
 	Translations         []PlanTranslation `gorm:"constraint:OnDelete:CASCADE;"`
 }
@@ -136,6 +143,8 @@ type Preset struct {
 	PoliciesID   *uint
 	Plan         *Plan `gorm:"constraint:OnDelete:CASCADE;"`
 	PlanID       *uint
+	StartTime    *time.Time          `gorm:"index"`
+	EndTime      *time.Time          `gorm:"index"`
 	Translations []PresetTranslation `gorm:"constraint:OnDelete:CASCADE;"`
 }
 
@@ -148,17 +157,138 @@ type PresetTranslation struct {
 	PresetID    uint
 }
 
-type Result struct {
+type Banner struct {
 	ID            uint
-	UserID        string `gorm:"index;notNull"`
-	Public        bool
-	Request       datatypes.JSON
-	ItemIDs       datatypes.JSON
-	GoalsAchieved bool
-	MoneySpent    float64
-	Time          time.Time
 	GameTitle     *GameTitle `gorm:"constraint:OnDelete:CASCADE;"`
 	GameTitleID   uint
+	RestrictItems bool
+	StartTime     *time.Time          `gorm:"index"`
+	EndTime       *time.Time          `gorm:"index"`
+	Tiers         []BannerTier        `gorm:"constraint:OnDelete:CASCADE;"`
+	Items         []BannerItem        `gorm:"constraint:OnDelete:CASCADE;"`
+	Translations  []BannerTranslation `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+type BannerTranslation struct {
+	ID          uint
+	Name        string
+	Description string
+	Language    string
+	Banner      *Banner
+	BannerID    uint
+}
+
+type BannerTier struct {
+	ID       uint
+	Ratio    *int
+	Banner   *Banner
+	BannerID uint
+	Tier     *Tier `gorm:"constraint:OnDelete:CASCADE;"`
+	TierID   uint
+}
+
+type BannerItem struct {
+	ID       uint
+	Ratio    *int
+	Excluded bool
+	Featured bool
+	Boost    int
+	Banner   *Banner
+	BannerID uint
+	Item     *Item `gorm:"constraint:OnDelete:CASCADE;"`
+	ItemID   uint
+}
+
+type Result struct {
+	ID                   uint
+	UserID               string `gorm:"index;notNull"`
+	Public               bool   `gorm:"index"`
+	Pinned               bool   `gorm:"index"`
+	Request              datatypes.JSON
+	ItemIDs              datatypes.JSON
+	Pulls                datatypes.JSON
+	GoalsAchieved        bool
+	GoalsAchievedViaShop bool
+	MoneySpent           float64
+	Duplicates           int
+	ConversionCurrency   float64
+	ShopPurchases        datatypes.JSON
+	StopReason           string   `gorm:"index"`
+	LuckPercentile       *float64 `gorm:"index"`
+	Time                 time.Time
+	GameTitle            *GameTitle `gorm:"constraint:OnDelete:CASCADE;"`
+	GameTitleID          uint
+	Banner               *Banner `gorm:"constraint:OnDelete:SET NULL;"`
+	BannerID             *uint
+	Preset               *Preset `gorm:"constraint:OnDelete:SET NULL;"`
+	PresetID             *uint
+	PresetUnmodified     bool
+}
+
+type ShareLink struct {
+	ID        uint
+	Token     string  `gorm:"size:64;unique;index;notNull"`
+	Result    *Result `gorm:"constraint:OnDelete:CASCADE;"`
+	ResultID  uint
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+}
+
+type GachaSession struct {
+	ID           uint
+	UserID       string     `gorm:"index;notNull"`
+	GameTitle    *GameTitle `gorm:"constraint:OnDelete:CASCADE;"`
+	GameTitleID  uint
+	GachaRequest datatypes.JSON
+	Request      datatypes.JSON
+	State        datatypes.JSON
+	RNGState     int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time `gorm:"index"`
+}
+
+type SimulationJob struct {
+	ID              uint
+	UserID          string     `gorm:"index;notNull"`
+	GameTitle       *GameTitle `gorm:"constraint:OnDelete:CASCADE;"`
+	GameTitleID     uint
+	Request         datatypes.JSON
+	Runs            int
+	Status          string `gorm:"size:32;index"`
+	Progress        int
+	Attempts        int
+	CancelRequested bool
+	Summary         datatypes.JSON
+	Error           string
+	CreatedAt       time.Time
+	StartedAt       *time.Time
+	HeartbeatAt     *time.Time
+	FinishedAt      *time.Time
+}
+
+type JobRun struct {
+	ID          uint
+	Name        string     `gorm:"size:256;index;uniqueIndex:idx_job_runs_name_scheduled_at;notNull"`
+	ScheduledAt *time.Time `gorm:"uniqueIndex:idx_job_runs_name_scheduled_at"`
+	StartedAt   time.Time  `gorm:"index"`
+	FinishedAt  *time.Time
+	DurationMs  int64
+	Status      string
+	Error       string
+}
+
+type AuditLog struct {
+	ID            uint
+	ClientID      string `gorm:"index"`
+	Method        string
+	Route         string
+	GameTitleSlug string `gorm:"index"`
+	PayloadHash   string
+	Status        int
+	Outcome       string
+	Error         string
+	Time          time.Time `gorm:"index"`
 }
 
 var DB *gorm.DB
@@ -198,11 +328,25 @@ func SetupDB(dsn string) {
 		&PlanTranslation{},
 		&Preset{},
 		&PresetTranslation{},
+		&Banner{},
+		&BannerTranslation{},
+		&BannerTier{},
+		&BannerItem{},
 		&Result{},
+		&ShareLink{},
+		&AuditLog{},
+		&JobRun{},
+		&SimulationJob{},
+		&GachaSession{},
 	)
 	if err != nil {
 		panic(err)
 	}
+	if db.Migrator().HasConstraint(&GameTitle{}, "game_titles_slug_key") {
+		if err := db.Migrator().DropConstraint(&GameTitle{}, "game_titles_slug_key"); err != nil {
+			panic(err)
+		}
+	}
 	DB = db
 }
 
@@ -311,3 +455,17 @@ func (preset Preset) GetLanguageHolders() []LanguageHolder {
 	}
 	return languageHolders
 }
+
+func (banner Banner) GetLanguageHolders() []LanguageHolder {
+	var languageHolders []LanguageHolder
+	for i := 0; i < len(banner.Translations); i++ {
+		languageHolders = append(languageHolders, LanguageHolder{
+			GetLanguage: func(i int) func() string {
+				return func() string {
+					return banner.Translations[i].Language
+				}
+			}(i),
+		})
+	}
+	return languageHolders
+}